@@ -0,0 +1,94 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"perScoreAuth/models"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var auditTenant string
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the security audit log",
+	Long:  ``,
+}
+
+// auditVerifyCmd represents the audit verify command
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the audit log hash chain",
+	Long: `Walk the hash chain of the audit log, tenant by tenant, and report the
+first missing or modified event. Checkpoint signatures are verified with the
+configured audit_signing_key. Chains without signed checkpoints are reported,
+since removing their newest events or rewriting them whole goes unnoticed.
+Exits with a non-zero status when a chain is broken.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := models.OpenDatabase(env)
+		if err != nil {
+			log.Errorf("Error opening DB connection: %+v", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		tenants := []string{auditTenant}
+		if auditTenant == "" {
+			if tenants, err = models.AuditTenants(db); err != nil {
+				log.Errorf("Error listing audit tenants: %+v", err)
+				os.Exit(1)
+			}
+		}
+		if len(models.AuditSigningKey) == 0 {
+			fmt.Println("Warning: audit_signing_key is not set, checkpoints are neither written nor verified")
+		}
+		if unchained, err := models.CountUnchainedAuditEvents(db); err == nil && unchained > 0 {
+			fmt.Printf("Warning: %d events recorded before chaining was enabled are not covered\n", unchained)
+		}
+
+		broken := false
+		for _, tenant := range tenants {
+			report, err := models.VerifyAuditChain(db, tenant, models.AuditSigningKey)
+			if err != nil {
+				log.Errorf("Error verifying audit chain of %s: %+v", tenant, err)
+				os.Exit(1)
+			}
+			if report.Break != nil {
+				broken = true
+				fmt.Printf("%s: BROKEN at %s (%d events verified before it)\n", tenant, report.Break, report.Events)
+			} else if report.Checkpoints == 0 && report.Events > 0 {
+				fmt.Printf("%s: OK, %d events verified, but no signed checkpoints exist: removing the newest events or rewriting the chain is not detected\n", tenant, report.Events)
+			} else {
+				fmt.Printf("%s: OK, %d events and %d checkpoints verified\n", tenant, report.Events, report.Checkpoints)
+			}
+		}
+
+		if broken {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+
+	auditVerifyCmd.Flags().StringVar(&auditTenant, "tenant", "", "Only verify the chain of this tenant")
+}
@@ -0,0 +1,114 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"perScoreAuth/models"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// dbCmd represents the db command
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Administer the database server",
+	Long:  ``,
+}
+
+// dbBootstrapCmd represents the db bootstrap command
+var dbBootstrapCmd = &cobra.Command{
+	Use:   "bootstrap [env...]",
+	Short: "Create the database role and database if they are missing",
+	Long: `Connect to Postgres as an administrator and create the login role and the
+database of each environment (the --env environment when none is given), for
+example:
+
+  perScoreAuth db bootstrap dev test
+
+The role and database are read from the <ENV>_USERNAME, <ENV>_PASSWORD and
+<ENV>_DBNAME variables. The administrator connection is configured with
+db_admin_username (default postgres), db_admin_password, db_admin_dbname
+(default postgres), db_admin_host and db_admin_sslmode, in the config file or
+as DB_ADMIN_* variables; host and sslmode default to the environment's.
+
+Existing roles and databases are left as they are, so it is safe to run again.
+Run migrate up afterwards to create the tables. Environments using SQLite need
+no bootstrapping.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		envs := args
+		if len(envs) == 0 {
+			envs = []string{env}
+		}
+
+		failed := false
+		for _, e := range envs {
+			config := models.DatabaseConfigFor(e)
+			if config.Driver == models.DriverSQLite {
+				fmt.Printf("%s: nothing to do, SQLite creates %s when it is first opened\n", e, config.Name)
+				continue
+			}
+			admin, err := adminDatabaseConfig(config).Open()
+			if err != nil {
+				log.Errorf("Error connecting to Postgres as %s: %+v", viper.GetString("db_admin_username"), err)
+				os.Exit(1)
+			}
+
+			actions, err := models.BootstrapDatabase(admin, config)
+			admin.Close()
+			for _, action := range actions {
+				fmt.Printf("%s: %s\n", e, action)
+			}
+			if err != nil {
+				log.Errorf("Error bootstrapping the %s database: %+v", e, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// adminDatabaseConfig returns the administrator connection used to
+// bootstrap the database described by config
+func adminDatabaseConfig(config models.DatabaseConfig) models.DatabaseConfig {
+	admin := models.DatabaseConfig{
+		Driver:   "postgres",
+		Host:     viper.GetString("db_admin_host"),
+		Name:     viper.GetString("db_admin_dbname"),
+		Username: viper.GetString("db_admin_username"),
+		Password: viper.GetString("db_admin_password"),
+		SSLMode:  viper.GetString("db_admin_sslmode"),
+	}
+	if admin.Host == "" {
+		admin.Host = config.Host
+	}
+	if admin.SSLMode == "" {
+		admin.SSLMode = config.SSLMode
+	}
+	return admin
+}
+
+func init() {
+	RootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbBootstrapCmd)
+
+	viper.SetDefault("db_admin_username", "postgres")
+	viper.SetDefault("db_admin_dbname", "postgres")
+}
@@ -0,0 +1,174 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"perScoreAuth/models"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateAll bool
+	migrateDir string
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply, revert and create database migrations",
+	Long: `Manage the database schema through versioned migrations. Migrations are
+SQL files in models/migrations, embedded in the binary, and applied versions
+are recorded in the schema_migrations table.`,
+}
+
+// migrateUpCmd represents the migrate up command
+var migrateUpCmd = &cobra.Command{
+	Use:   "up [steps]",
+	Short: "Apply pending migrations",
+	Long:  `Apply the pending migrations in order, or only the next [steps] of them.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := migrateSteps(args, 0)
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		applied, err := models.MigrateUp(db, steps)
+		for _, m := range applied {
+			fmt.Printf("Applied %s\n", m)
+		}
+		if err != nil {
+			log.Errorf("Error applying migrations: %+v", err)
+			os.Exit(1)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	},
+}
+
+// migrateDownCmd represents the migrate down command
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [steps]",
+	Short: "Revert applied migrations",
+	Long:  `Revert the newest applied migration, the newest [steps] of them, or all of them with --all.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		steps := migrateSteps(args, 1)
+		if migrateAll {
+			steps = 0
+		}
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		reverted, err := models.MigrateDown(db, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %s\n", m)
+		}
+		if err != nil {
+			log.Errorf("Error reverting migrations: %+v", err)
+			os.Exit(1)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+	},
+}
+
+// migrateStatusCmd represents the migrate status command
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they are applied",
+	Long:  `List migrations and whether they are applied. Exits with a non-zero status when some are pending.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		statuses, err := models.MigrationStatuses(db)
+		if err != nil {
+			log.Errorf("Error reading migrations: %+v", err)
+			os.Exit(1)
+		}
+
+		pending := false
+		for _, status := range statuses {
+			switch {
+			case status.Unknown:
+				fmt.Printf("%-40s applied %s, not in this binary\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			case status.Applied:
+				fmt.Printf("%-40s applied %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				pending = true
+				fmt.Printf("%-40s pending\n", status.Migration)
+			}
+		}
+		if pending {
+			os.Exit(1)
+		}
+	},
+}
+
+// migrateCreateCmd represents the migrate create command
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create the files of a new migration",
+	Long: `Create empty up and down files for a new migration, numbered after the
+newest one. Rebuild the binary to embed them.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths, err := models.CreateMigration(migrateDir, args[0])
+		if err != nil {
+			log.Errorf("Error creating migration: %+v", err)
+			os.Exit(1)
+		}
+		for _, path := range paths {
+			fmt.Printf("Created %s\n", path)
+		}
+	},
+}
+
+func openMigrationDatabase() *gorm.DB {
+	db, err := models.OpenDatabase(env)
+	if err != nil {
+		log.Errorf("Error opening DB connection: %+v", err)
+		os.Exit(1)
+	}
+	return db
+}
+
+func migrateSteps(args []string, fallback int) int {
+	if len(args) == 0 {
+		return fallback
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		log.Errorf("steps must be a positive number, got %q", args[0])
+		os.Exit(1)
+	}
+	return steps
+}
+
+func init() {
+	RootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateCreateCmd)
+
+	migrateDownCmd.Flags().BoolVar(&migrateAll, "all", false, "Revert all applied migrations")
+	migrateCreateCmd.Flags().StringVar(&migrateDir, "dir", models.MigrationsDir, "Directory of the migration files")
+}
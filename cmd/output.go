@@ -0,0 +1,137 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/net/context"
+)
+
+// Output formats of the admin commands
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+var errNotConfirmed = errors.New("aborted")
+
+// printOutput writes rows as an aligned table with header, or value as
+// indented JSON, depending on format
+func printOutput(format string, value interface{}, header []string, rows [][]string) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case outputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, expected %s or %s", format, outputTable, outputJSON)
+	}
+}
+
+func stdinIsTerminal() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// confirm asks the operator to confirm a destructive action. Without a
+// terminal the action must be confirmed up front with --yes.
+func confirm(assumeYes bool, format string, args ...interface{}) error {
+	if assumeYes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		return errors.New("stdin is not a terminal, pass --yes to confirm")
+	}
+
+	fmt.Printf(format+" [y/N] ", args...)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return errNotConfirmed
+	}
+}
+
+// readPassword prompts twice for a password on a terminal, or reads one line
+// from stdin otherwise
+func readPassword() (string, error) {
+	if !stdinIsTerminal() {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no password given on stdin")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Print("Password: ")
+	password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	fmt.Print("Repeat password: ")
+	repeated, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(repeated) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(password), nil
+}
+
+// cliContext records the operator running the command as the actor of
+// audit events
+func cliContext() context.Context {
+	actor := "cli"
+	if current, err := user.Current(); err == nil {
+		actor = "cli:" + current.Username
+	}
+	return models.WithAuditContext(context.Background(), models.AuditContext{
+		Actor:     actor,
+		UserAgent: "perScoreAuth-cli",
+	})
+}
+
+// printFields writes the failed validations of a user, one per line
+func printFields(indent string, fields []*pb.CreateUserResponse_Field) {
+	for _, field := range fields {
+		if field.Message != "" {
+			fmt.Printf("%s%s: %s (%s)\n", indent, field.Name, field.Validation, field.Message)
+		} else {
+			fmt.Printf("%s%s: %s\n", indent, field.Name, field.Validation)
+		}
+	}
+}
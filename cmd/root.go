@@ -17,14 +17,20 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"perScoreAuth/models"
 
 	homedir "github.com/mitchellh/go-homedir"
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// env selects the database environment commands work against
+var env string
+
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "perScoreAuth",
@@ -56,6 +62,9 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.perScoreAuth.yaml)")
+	RootCmd.PersistentFlags().StringVar(&env, "env", "dev", "database environment to use, e.g. dev or test")
+	RootCmd.PersistentFlags().String("log-level", "info", "log level: debug, info, warn or error")
+	viper.BindPFlag("log_level", RootCmd.PersistentFlags().Lookup("log-level"))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -86,4 +95,81 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	configureLogging()
+	configureModels()
+}
+
+// configureLogging makes logrus write JSON at the configured level
+func configureLogging() {
+	log.SetFormatter(&log.JSONFormatter{})
+	level, err := log.ParseLevel(viper.GetString("log_level"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	log.SetLevel(level)
+}
+
+// configureModels applies the settings read by viper to the models package
+func configureModels() {
+	models.AuditSigningKey = []byte(viper.GetString("audit_signing_key"))
+	if viper.IsSet("audit_checkpoint_interval") {
+		models.AuditCheckpointInterval = uint64(viper.GetInt64("audit_checkpoint_interval"))
+	}
+	if viper.IsSet("password_history_size") {
+		models.PasswordHistorySize = viper.GetInt("password_history_size")
+	}
+
+	policies, err := passwordPolicies()
+	if err != nil {
+		fmt.Println("Invalid password_policy:", err)
+		os.Exit(1)
+	}
+	models.PasswordPolicies = policies
+	if path := viper.GetString("password_denylist_file"); path != "" {
+		denylist, err := models.LoadPasswordDenylist(path)
+		if err != nil {
+			fmt.Println("Error loading the password denylist:", err)
+			os.Exit(1)
+		}
+		models.CommonPasswords = denylist
+	}
+	if path := viper.GetString("breached_passwords_file"); path != "" {
+		corpus, err := models.OpenBreachCorpus(path)
+		if err != nil {
+			fmt.Println("Error opening the breached passwords file:", err)
+			os.Exit(1)
+		}
+		models.BreachedPasswords = corpus
+	}
+}
+
+// passwordPolicies reads the password_policy setting: the rules of the
+// default policy, and overrides naming a tenant, a role or both. Rules an
+// override leaves out are those of the default policy.
+func passwordPolicies() (*models.PasswordPolicySet, error) {
+	base := models.DefaultPasswordPolicy
+	if err := viper.UnmarshalKey("password_policy", &base); err != nil {
+		return nil, err
+	}
+	policies := models.NewPasswordPolicySet(base)
+
+	var overrides []map[string]interface{}
+	if err := viper.UnmarshalKey("password_policy.overrides", &overrides); err != nil {
+		return nil, err
+	}
+	for i, override := range overrides {
+		tenant, _ := override["tenant"].(string)
+		role, _ := override["role"].(string)
+		if tenant == "" && role == "" {
+			return nil, fmt.Errorf("override %d names neither a tenant nor a role", i+1)
+		}
+		policy := base
+		if err := mapstructure.WeakDecode(override, &policy); err != nil {
+			return nil, fmt.Errorf("override %d: %v", i+1, err)
+		}
+		policies.Set(tenant, role, policy)
+	}
+	return policies, nil
 }
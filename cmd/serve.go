@@ -15,31 +15,115 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
 	"perScoreAuth/server"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the perScoreAuth server",
-	Long:  ``,
+	Long: `Start the perScoreAuth gRPC server.
+
+TLS is enabled when a certificate and key are configured. When a client CA
+bundle is configured as well, client certificates are verified and each RPC
+is only accepted from the callers listed for it under "authorization" in the
+config file, for example:
+
+  authorization:
+    CreateUser:
+      - perScoreServer
+      - spiffe://perscore.local/perScoreServer
+
+Callers act for the tenant named in the x-tenant-id header only when they are
+listed for it under "tenants"; a caller listed for a single tenant acts for it
+without naming it. By default perScoreServer may act for any tenant:
+
+  tenants:
+    - caller: perScoreServer
+      tenants: ["*"]
+    - caller: spiffe://perscore.local/acme-portal
+      tenants: [acme]
+
+Without TLS and a client CA bundle callers cannot be authorized, and the
+server refuses to start unless --insecure-allow-all is set.
+
+Certificate, key and CA files are reloaded when they change on disk.
+
+The User service is also served as HTTP/JSON on --gateway-address, for
+example POST /v1/users and POST /v1/sessions, using the same TLS settings.
+
+The server starts before the database is reachable. The grpc.health.v1
+service and /readyz report NOT_SERVING until the database connects and all
+migrations are applied, and again whenever a periodic probe fails.
+
+On SIGINT or SIGTERM the server reports NOT_SERVING, stops accepting requests
+and waits up to --shutdown-timeout for the ones in flight before cancelling
+them and closing the database.
+
+CreateUser, ChangePassword and RequirePasswordChange requests sent with an
+idempotency-key header run once: retries with the same key and request get
+the stored response for --idempotency-window. A key whose request never
+finished is taken over by a retry after --idempotency-lease.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		server.StartServer()
+		var tenants []server.TenantBinding
+		if err := viper.UnmarshalKey("tenants", &tenants); err != nil {
+			fmt.Println("Invalid tenants:", err)
+			os.Exit(1)
+		}
+		server.StartServer(server.Config{
+			Address:             viper.GetString("address"),
+			Env:                 env,
+			GatewayAddress:      viper.GetString("gateway_address"),
+			MetricsAddress:      viper.GetString("metrics_address"),
+			HealthCheckInterval: viper.GetDuration("health_check_interval"),
+			ShutdownTimeout:     viper.GetDuration("shutdown_timeout"),
+			TLSCertFile:         viper.GetString("tls_cert"),
+			TLSKeyFile:          viper.GetString("tls_key"),
+			TLSClientCAFile:     viper.GetString("tls_client_ca"),
+			RequireClientCert:   viper.GetBool("tls_require_client_cert"),
+			InsecureAllowAll:    viper.GetBool("insecure_allow_all"),
+			Authorization:       viper.GetStringMapStringSlice("authorization"),
+			Tenants:             tenants,
+			RedactFields:        viper.GetStringSlice("log_redact_fields"),
+			IdempotencyWindow:   viper.GetDuration("idempotency_window"),
+			IdempotencyLease:    viper.GetDuration("idempotency_lease"),
+		})
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(serveCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// serveCmd.PersistentFlags().String("foo", "", "A help for foo")
+	// Flags can also be set in the config file or through the environment,
+	// e.g. TLS_CERT=/etc/perscoreauth/tls.crt
+	serveCmd.Flags().String("address", server.DefaultAddress, "Address to listen on")
+	serveCmd.Flags().String("gateway-address", server.DefaultGatewayAddress, "Address of the HTTP/JSON gateway, empty to disable")
+	serveCmd.Flags().String("metrics-address", server.DefaultMetricsAddress, "Address of the HTTP server exposing /metrics, /healthz and /readyz, empty to disable")
+	serveCmd.Flags().Duration("health-check-interval", server.DefaultHealthCheckInterval, "How often the database is probed for readiness")
+	serveCmd.Flags().Duration("shutdown-timeout", server.DefaultShutdownTimeout, "How long in-flight requests are drained on SIGINT or SIGTERM")
+	serveCmd.Flags().Duration("idempotency-window", server.DefaultIdempotencyWindow, "How long responses to requests with an idempotency-key are replayed")
+	serveCmd.Flags().Duration("idempotency-lease", server.DefaultIdempotencyLease, "How long a request holds its idempotency-key before a retry may take it over")
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file")
+	serveCmd.Flags().String("tls-client-ca", "", "CA bundle used to verify client certificates")
+	serveCmd.Flags().Bool("tls-require-client-cert", false, "Reject clients that do not present a certificate")
+	serveCmd.Flags().Bool("insecure-allow-all", false, "Start without TLS or a client CA bundle, letting any client call every RPC")
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// serveCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	viper.BindPFlag("address", serveCmd.Flags().Lookup("address"))
+	viper.BindPFlag("gateway_address", serveCmd.Flags().Lookup("gateway-address"))
+	viper.BindPFlag("metrics_address", serveCmd.Flags().Lookup("metrics-address"))
+	viper.BindPFlag("health_check_interval", serveCmd.Flags().Lookup("health-check-interval"))
+	viper.BindPFlag("shutdown_timeout", serveCmd.Flags().Lookup("shutdown-timeout"))
+	viper.BindPFlag("idempotency_window", serveCmd.Flags().Lookup("idempotency-window"))
+	viper.BindPFlag("idempotency_lease", serveCmd.Flags().Lookup("idempotency-lease"))
+	viper.BindPFlag("tls_cert", serveCmd.Flags().Lookup("tls-cert"))
+	viper.BindPFlag("tls_key", serveCmd.Flags().Lookup("tls-key"))
+	viper.BindPFlag("tls_client_ca", serveCmd.Flags().Lookup("tls-client-ca"))
+	viper.BindPFlag("tls_require_client_cert", serveCmd.Flags().Lookup("tls-require-client-cert"))
+	viper.BindPFlag("insecure_allow_all", serveCmd.Flags().Lookup("insecure-allow-all"))
 }
@@ -15,42 +15,19 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-	"perScoreAuth/models"
-
-	"github.com/jinzhu/gorm"
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-// setupdbCmd represents the setupdb command
+// setupdbCmd represents the setupdb command. It is kept for existing scripts
+// and applies the pending migrations like migrate up.
 var setupdbCmd = &cobra.Command{
 	Use:   "setupdb",
-	Short: "Create and Migrate db Schema",
+	Short: "Apply pending database migrations (alias of migrate up)",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("setupdb called")
-		dbString := fmt.Sprintf("host=%s dbname=%s user=%s password=%s sslmode=%s", os.Getenv("DEV_HOST"), os.Getenv("DEV_DBNAME"), os.Getenv("DEV_USERNAME"), os.Getenv("DEV_PASSWORD"), os.Getenv("DEV_SSLMODE"))
-		db, err := gorm.Open(os.Getenv("DEV_DB_DRIVER"), dbString)
-		defer db.Close()
-		if err != nil {
-			log.Errorf("Error in setup database: %+v", err)
-		}
-		models.SetupDatabase(db)
-	},
+	Args:  migrateUpCmd.Args,
+	Run:   migrateUpCmd.Run,
 }
 
 func init() {
 	RootCmd.AddCommand(setupdbCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// setupdbCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// setupdbCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
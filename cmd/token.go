@@ -0,0 +1,208 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"perScoreAuth/models"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	tokenOutput string
+	tokenUser   string
+	tokenRole   string
+	tokenTTL    time.Duration
+)
+
+// tokenCmd represents the token command
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Mint, inspect and verify session tokens",
+	Long: `Work with session tokens locally, using the same key as the server. Tokens
+are read from the argument, or from stdin when it is "-" or missing.`,
+}
+
+// tokenInspectCmd represents the token inspect command
+var tokenInspectCmd = &cobra.Command{
+	Use:   "inspect [token]",
+	Short: "Decode a token and show its claims",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		raw := readToken(args)
+		token, err := models.ParseToken(raw)
+		if err != nil {
+			log.Errorf("%+v", err)
+			os.Exit(1)
+		}
+		_, verifyErr := models.VerifyToken(raw, time.Now())
+
+		view := tokenView{
+			Email:      token.Email,
+			Role:       token.Role,
+			TTLMinutes: int64(token.TTL / time.Minute),
+			KeyID:      token.KeyID,
+			Valid:      verifyErr == nil,
+		}
+		if !token.IssuedAt.IsZero() {
+			issuedAt, expiresAt := token.IssuedAt, token.ExpiresAt()
+			view.IssuedAt, view.ExpiresAt = &issuedAt, &expiresAt
+		}
+		if verifyErr != nil {
+			view.Problem = verifyErr.Error()
+		}
+		printTokenView(view)
+	},
+}
+
+// tokenVerifyCmd represents the token verify command
+var tokenVerifyCmd = &cobra.Command{
+	Use:   "verify [token]",
+	Short: "Exit with a non-zero status unless a token is valid",
+	Long: `Check that a token was issued with the current key and has not expired.
+Prints the token's email and exits with status 0 when it is valid, prints the
+problem and exits with status 1 otherwise.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := models.VerifyToken(readToken(args), time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("valid: %s (%s) until %s\n", token.Email, token.Role, token.ExpiresAt().Format(time.RFC3339))
+	},
+}
+
+// tokenMintCmd represents the token mint command
+var tokenMintCmd = &cobra.Command{
+	Use:   "mint",
+	Short: "Create a token for development",
+	Long: `Create a token for any user and role without logging in. Only allowed for
+the environments listed in token_mint_envs (default dev and test), which must
+be selected explicitly with --env or the token_mint_env setting: the default
+environment does not allow minting.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mintEnv := tokenMintEnv()
+		if mintEnv == "" {
+			log.Error("Minting tokens needs the environment set explicitly with --env or token_mint_env")
+			os.Exit(1)
+		}
+		if !tokenMintAllowed(mintEnv) {
+			log.Errorf("Minting tokens is not allowed in the %s environment, see token_mint_envs", mintEnv)
+			os.Exit(1)
+		}
+		if tokenUser == "" || tokenRole == "" {
+			log.Error("--user and --role are required")
+			os.Exit(1)
+		}
+		if !models.IsRole(tokenRole) {
+			log.Errorf("Unknown role %q, expected one of %v", tokenRole, models.Roles)
+			os.Exit(1)
+		}
+		fmt.Println(models.IssueToken(tokenUser, tokenRole, tokenTTL, time.Now()))
+	},
+}
+
+// tokenView is the output of token inspect
+type tokenView struct {
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	TTLMinutes int64      `json:"ttl_minutes"`
+	IssuedAt   *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	KeyID      string     `json:"key_id,omitempty"`
+	Valid      bool       `json:"valid"`
+	Problem    string     `json:"problem,omitempty"`
+}
+
+func printTokenView(view tokenView) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "unknown"
+		}
+		return t.Format(time.RFC3339)
+	}
+	keyID := view.KeyID
+	if keyID == "" {
+		keyID = "unknown"
+	} else if keyID == models.KeyID() {
+		keyID += " (current key)"
+	}
+	valid := "yes"
+	if !view.Valid {
+		valid = "no, " + view.Problem
+	}
+
+	rows := [][]string{
+		{"Email", view.Email},
+		{"Role", view.Role},
+		{"TTL", fmt.Sprintf("%d minutes", view.TTLMinutes)},
+		{"Issued at", formatTime(view.IssuedAt)},
+		{"Expires at", formatTime(view.ExpiresAt)},
+		{"Key ID", keyID},
+		{"Valid", valid},
+	}
+	if err := printOutput(tokenOutput, view, []string{"CLAIM", "VALUE"}, rows); err != nil {
+		log.Errorf("%+v", err)
+		os.Exit(1)
+	}
+}
+
+// readToken returns the token argument, or the first line of stdin
+func readToken(args []string) string {
+	if len(args) == 1 && args[0] != "-" {
+		return args[0]
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// tokenMintEnv returns the environment tokens are minted for: --env when it
+// was passed, token_mint_env otherwise, and nothing when neither was set
+func tokenMintEnv() string {
+	if RootCmd.PersistentFlags().Changed("env") {
+		return env
+	}
+	return viper.GetString("token_mint_env")
+}
+
+func tokenMintAllowed(env string) bool {
+	for _, allowed := range viper.GetStringSlice("token_mint_envs") {
+		if strings.EqualFold(env, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	RootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenInspectCmd, tokenVerifyCmd, tokenMintCmd)
+
+	tokenInspectCmd.Flags().StringVarP(&tokenOutput, "output", "o", outputTable, "Output format: table or json")
+	tokenMintCmd.Flags().StringVar(&tokenUser, "user", "", "Email address of the user")
+	tokenMintCmd.Flags().StringVar(&tokenRole, "role", "", fmt.Sprintf("Role, one of %v", models.Roles))
+	tokenMintCmd.Flags().DurationVar(&tokenTTL, "ttl", models.SessionDuration, "How long the token is valid, in whole minutes")
+
+	viper.SetDefault("token_mint_envs", []string{"dev", "test"})
+}
@@ -0,0 +1,413 @@
+// Copyright © 2017 NAME HERE <EMAIL ADDRESS>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	userOutput    string
+	userYes       bool
+	userFilter    models.UserFilter
+	userCreateReq = pb.CreateUserRequest{Location: &pb.CreateUserRequest_Location{}}
+	userFile      string
+	userFormat    string
+	userFields    []string
+	userDryRun    bool
+	userAll       bool
+	// userCreatedBefore is a date or RFC 3339 time
+	userCreatedBefore string
+)
+
+// userCmd represents the user command
+var userCmd = &cobra.Command{
+	Use:     "user",
+	Aliases: []string{"users"},
+	Short:   "Manage user accounts",
+	Long: `Manage user accounts of the --env database. Changes go through the same
+validation, password handling and audit log as the service.`,
+}
+
+// userCreateCmd represents the user create command
+var userCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a user",
+	Long: `Create a user. The password is prompted for on a terminal, or read from the
+first line of stdin otherwise.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := readPassword()
+		if err != nil {
+			log.Errorf("Error reading the password: %+v", err)
+			os.Exit(1)
+		}
+		userCreateReq.Password = password
+
+		store := openUserStore()
+		defer store.Close()
+
+		response, err := models.User{}.CreateInDB(cliContext(), &userCreateReq, store)
+		if err != nil {
+			printFields("", response.Fields)
+			log.Errorf("Error creating user: %+v", err)
+			os.Exit(1)
+		}
+		showUser(store, userCreateReq.Email)
+	},
+}
+
+// userListCmd represents the user list command
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openUserStore()
+		defer store.Close()
+
+		users, err := store.ListUsers(userFilter)
+		if err != nil {
+			log.Errorf("Error listing users: %+v", err)
+			os.Exit(1)
+		}
+		printUsers(users)
+	},
+}
+
+// userShowCmd represents the user show command
+var userShowCmd = &cobra.Command{
+	Use:   "show <email>",
+	Short: "Show a user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openUserStore()
+		defer store.Close()
+		showUser(store, args[0])
+	},
+}
+
+// userDisableCmd represents the user disable command
+var userDisableCmd = &cobra.Command{
+	Use:   "disable <email>",
+	Short: "Prevent a user from logging in",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openUserStore()
+		defer store.Close()
+
+		exitOnUserError(confirm(userYes, "Disable %s?", args[0]))
+		exitOnUserError(models.DisableUser(cliContext(), store, args[0]))
+		fmt.Printf("Disabled %s\n", args[0])
+	},
+}
+
+// userSetRoleCmd represents the user set-role command
+var userSetRoleCmd = &cobra.Command{
+	Use:   "set-role <email> <role>",
+	Short: "Change the role of a user",
+	Long:  fmt.Sprintf("Change the role of a user to one of %v.", models.Roles),
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openUserStore()
+		defer store.Close()
+
+		exitOnUserError(confirm(userYes, "Change the role of %s to %s?", args[0], args[1]))
+		exitOnUserError(models.SetUserRole(cliContext(), store, args[0], args[1]))
+		fmt.Printf("%s is now %s\n", args[0], args[1])
+	},
+}
+
+// userResetPasswordCmd represents the user reset-password command
+var userResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password <email>",
+	Short: "Replace the password of a user",
+	Long: `Replace the password of a user. The new password is prompted for on a
+terminal, or read from the first line of stdin together with --yes.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openUserStore()
+		defer store.Close()
+
+		exitOnUserError(confirm(userYes, "Reset the password of %s?", args[0]))
+		password, err := readPassword()
+		exitOnUserError(err)
+		exitOnUserError(models.ResetPassword(cliContext(), store, args[0], password))
+		fmt.Printf("Reset the password of %s\n", args[0])
+	},
+}
+
+// userRequirePasswordChangeCmd represents the user require-password-change command
+var userRequirePasswordChangeCmd = &cobra.Command{
+	Use:   "require-password-change [email...]",
+	Short: "Make users change their password at their next login",
+	Long: `Make users change their password at their next login, for example after
+an incident. Users are selected by email, --role and --created-before, and
+must match all of them; --all selects every user.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := models.UserFilter{Emails: args, Role: userFilter.Role}
+		if userCreatedBefore != "" {
+			createdBefore, err := parseDateTime(userCreatedBefore)
+			exitOnUserError(err)
+			filter.CreatedBefore = createdBefore
+		}
+
+		store := openUserStore()
+		defer store.Close()
+
+		exitOnUserError(confirm(userYes, "Require the selected users to change their password?"))
+		users, err := models.RequirePasswordChange(cliContext(), store, filter, userAll)
+		for _, user := range users {
+			fmt.Println(user.Email)
+		}
+		exitOnUserError(err)
+		fmt.Printf("%d users must change their password\n", len(users))
+	},
+}
+
+// parseDateTime parses a date, taken as midnight UTC, or an RFC 3339 time
+func parseDateTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// userImportCmd represents the user import command
+var userImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create users from a CSV or JSON Lines file",
+	Long: fmt.Sprintf(`Create users from a CSV file with a header row, or a JSON Lines file with
+one object per line. Columns and keys are named %s.
+Every user is validated like CreateUser and rows that fail are reported with
+their line number. With --dry-run nothing is written.`, strings.Join(models.UserImportFields, ", ")),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := userFileFormat()
+		file, err := os.Open(userFile)
+		exitOnUserError(err)
+		records, err := models.ReadUserRecords(file, format)
+		file.Close()
+		exitOnUserError(err)
+
+		store := openUserStore()
+		defer store.Close()
+
+		failed := 0
+		for _, result := range models.ImportUsers(cliContext(), store, records, userDryRun) {
+			if result.Err == nil {
+				continue
+			}
+			failed++
+			fmt.Printf("line %d (%s): %v\n", result.Line, result.Email, result.Err)
+			printFields("  ", result.Fields)
+		}
+
+		if userDryRun {
+			fmt.Printf("%d of %d users would be imported\n", len(records)-failed, len(records))
+		} else {
+			fmt.Printf("Imported %d of %d users\n", len(records)-failed, len(records))
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// userExportCmd represents the user export command
+var userExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write users to a CSV or JSON Lines file",
+	Long: fmt.Sprintf(`Write users to a CSV or JSON Lines file, or to stdout when --file is not
+given. --fields selects some of %s.
+Passwords are never exported.`, strings.Join(models.UserExportFields, ", ")),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := userFileFormat()
+		store := openUserStore()
+		defer store.Close()
+
+		users, err := store.ListUsers(userFilter)
+		exitOnUserError(err)
+
+		out := os.Stdout
+		if userFile != "" && userFile != "-" {
+			out, err = os.OpenFile(userFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			exitOnUserError(err)
+		}
+		exitOnUserError(models.WriteUsers(out, format, userFields, users))
+		exitOnUserError(out.Close())
+		if out != os.Stdout {
+			fmt.Printf("Exported %d users to %s\n", len(users), userFile)
+		}
+	},
+}
+
+// userFileFormat returns --format, or the format of --file
+func userFileFormat() string {
+	if userFormat != "" {
+		return userFormat
+	}
+	if userFile == "" || userFile == "-" {
+		exitOnUserError(fmt.Errorf("--format is required without --file"))
+	}
+	format, err := models.FormatFromPath(userFile)
+	exitOnUserError(err)
+	return format
+}
+
+// userView is the JSON representation of a user, without the password
+type userView struct {
+	ID         uint       `json:"id"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"first_name"`
+	LastName   string     `json:"last_name"`
+	Age        int32      `json:"age"`
+	Role       string     `json:"role"`
+	City       string     `json:"city"`
+	Country    string     `json:"country"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+	MustChangePassword bool       `json:"must_change_password"`
+}
+
+func newUserView(user models.User) userView {
+	return userView{
+		ID:         user.ID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Age:        user.Age,
+		Role:       user.Role,
+		City:       user.Location.City,
+		Country:    user.Location.Country,
+		CreatedAt:  user.CreatedAt,
+		DisabledAt: user.DisabledAt,
+
+		PasswordChangedAt:  user.PasswordChangedAt,
+		MustChangePassword: user.MustChangePassword,
+	}
+}
+
+var userHeader = []string{"ID", "EMAIL", "NAME", "ROLE", "AGE", "LOCATION", "CREATED", "DISABLED"}
+
+func (view userView) row() []string {
+	disabled := ""
+	if view.DisabledAt != nil {
+		disabled = view.DisabledAt.Format("2006-01-02 15:04")
+	}
+	return []string{
+		strconv.Itoa(int(view.ID)),
+		view.Email,
+		view.FirstName + " " + view.LastName,
+		view.Role,
+		strconv.Itoa(int(view.Age)),
+		view.City + ", " + view.Country,
+		view.CreatedAt.Format("2006-01-02 15:04"),
+		disabled,
+	}
+}
+
+func printUsers(users []models.User) {
+	views := make([]userView, 0, len(users))
+	var rows [][]string
+	for _, user := range users {
+		view := newUserView(user)
+		views = append(views, view)
+		rows = append(rows, view.row())
+	}
+	exitOnUserError(printOutput(userOutput, views, userHeader, rows))
+}
+
+func showUser(store models.Store, email string) {
+	user, err := store.FindUserByEmail(email)
+	exitOnUserError(err)
+	view := newUserView(user)
+	exitOnUserError(printOutput(userOutput, view, userHeader, [][]string{view.row()}))
+}
+
+func openUserStore() models.Store {
+	if userOutput != outputTable && userOutput != outputJSON {
+		exitOnUserError(fmt.Errorf("unknown output format %q, expected %s or %s", userOutput, outputTable, outputJSON))
+	}
+	db, err := models.OpenDatabase(env)
+	if err != nil {
+		log.Errorf("Error opening DB connection: %+v", err)
+		os.Exit(1)
+	}
+	return models.NewGormStore(db)
+}
+
+func exitOnUserError(err error) {
+	if err == errNotConfirmed {
+		fmt.Println("Aborted")
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Errorf("%+v", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userCreateCmd, userListCmd, userShowCmd, userDisableCmd, userSetRoleCmd, userResetPasswordCmd, userRequirePasswordChangeCmd, userImportCmd, userExportCmd)
+
+	userCmd.PersistentFlags().StringVarP(&userOutput, "output", "o", outputTable, "Output format: table or json")
+	for _, cmd := range []*cobra.Command{userDisableCmd, userSetRoleCmd, userResetPasswordCmd, userRequirePasswordChangeCmd} {
+		cmd.Flags().BoolVarP(&userYes, "yes", "y", false, "Do not ask for confirmation")
+	}
+
+	userCreateCmd.Flags().StringVar(&userCreateReq.Email, "email", "", "Email address, used to log in")
+	userCreateCmd.Flags().StringVar(&userCreateReq.FirstName, "first-name", "", "First name")
+	userCreateCmd.Flags().StringVar(&userCreateReq.LastName, "last-name", "", "Last name")
+	userCreateCmd.Flags().Int32Var(&userCreateReq.Age, "age", 0, "Age")
+	userCreateCmd.Flags().StringVar(&userCreateReq.Role, "role", "", fmt.Sprintf("Role, one of %v", models.Roles))
+	userCreateCmd.Flags().StringVar(&userCreateReq.Location.City, "city", "", "City")
+	userCreateCmd.Flags().StringVar(&userCreateReq.Location.Country, "country", "", "Country")
+
+	for _, cmd := range []*cobra.Command{userListCmd, userExportCmd} {
+		cmd.Flags().StringVar(&userFilter.Role, "role", "", "Only include users with this role")
+		cmd.Flags().BoolVar(&userFilter.IncludeDisabled, "include-disabled", false, "Include disabled users")
+		cmd.Flags().IntVar(&userFilter.Limit, "limit", 0, "Include at most this many users")
+	}
+
+	userRequirePasswordChangeCmd.Flags().StringVar(&userFilter.Role, "role", "", "Only include users with this role")
+	userRequirePasswordChangeCmd.Flags().StringVar(&userCreatedBefore, "created-before", "", "Only include users created before this date or time")
+	userRequirePasswordChangeCmd.Flags().BoolVar(&userAll, "all", false, "Include every user")
+
+	for _, cmd := range []*cobra.Command{userImportCmd, userExportCmd} {
+		cmd.Flags().StringVar(&userFile, "file", "", "File to read or write, its extension (.csv or .jsonl) sets the format")
+		cmd.Flags().StringVar(&userFormat, "format", "", "File format: csv or jsonl")
+	}
+	userImportCmd.MarkFlagRequired("file")
+	userImportCmd.Flags().BoolVar(&userDryRun, "dry-run", false, "Only validate the users, do not create them")
+	userExportCmd.Flags().StringSliceVar(&userFields, "fields", nil, "Fields to export, all when empty")
+}
@@ -0,0 +1,174 @@
+// Package metrics exposes Prometheus metrics about authentication traffic.
+// Labels are limited to small, fixed sets of values; never label a metric
+// with user supplied data such as an email address.
+package metrics
+
+import (
+	"container/heap"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "perscoreauth"
+
+var (
+	rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "gRPC requests handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Time spent handling gRPC requests, by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts, by outcome and failure reason.",
+	}, []string{"outcome", "reason"})
+
+	signups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Signup attempts, by role and outcome.",
+	}, []string{"role", "outcome"})
+
+	lockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Accounts locked out.",
+	})
+
+	activeSessions = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Sessions issued by this process that have not expired yet.",
+	}, func() float64 { return float64(sessions.active(time.Now())) })
+)
+
+func init() {
+	prometheus.MustRegister(rpcRequests, rpcDuration, logins, signups, lockouts, activeSessions)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRPC records a handled gRPC request
+func ObserveRPC(method, code string, duration time.Duration) {
+	rpcRequests.WithLabelValues(method, code).Inc()
+	rpcDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+// ObserveLogin records a login attempt. reason is empty for successful logins.
+func ObserveLogin(outcome, reason string) {
+	logins.WithLabelValues(outcome, reason).Inc()
+}
+
+// ObserveSignup records a signup attempt. role must be one of a fixed set of
+// values, callers map unknown roles to a catch-all value.
+func ObserveSignup(role, outcome string) {
+	signups.WithLabelValues(role, outcome).Inc()
+}
+
+// ObserveLockout records an account lockout
+func ObserveLockout() {
+	lockouts.Inc()
+}
+
+// SessionStarted records a session that is active until expiresAt
+func SessionStarted(expiresAt time.Time) {
+	sessions.add(expiresAt, time.Now())
+}
+
+// sessionTracker counts unexpired sessions. Sessions are stateless tokens, so
+// the count only covers sessions issued since this process started. Expired
+// sessions are dropped as sessions are added, whether or not metrics are
+// scraped.
+type sessionTracker struct {
+	mu     sync.Mutex
+	expiry expiryHeap
+}
+
+var sessions = &sessionTracker{}
+
+func (t *sessionTracker) add(expiresAt, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	heap.Push(&t.expiry, expiresAt)
+}
+
+func (t *sessionTracker) active(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	return t.expiry.Len()
+}
+
+// prune drops the sessions expired at now
+func (t *sessionTracker) prune(now time.Time) {
+	for t.expiry.Len() > 0 && !t.expiry[0].After(now) {
+		heap.Pop(&t.expiry)
+	}
+}
+
+// expiryHeap is a heap of expiry times, the earliest first
+type expiryHeap []time.Time
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].Before(h[j]) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(time.Time)) }
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// RegisterDBStats exposes the connection pool statistics of db
+func RegisterDBStats(db *sql.DB) error {
+	return prometheus.Register(&dbStatsCollector{db: db})
+}
+
+var (
+	dbOpenConnections = prometheus.NewDesc(namespace+"_db_open_connections", "Open connections to the database.", nil, nil)
+	dbInUse           = prometheus.NewDesc(namespace+"_db_in_use_connections", "Connections currently in use.", nil, nil)
+	dbIdle            = prometheus.NewDesc(namespace+"_db_idle_connections", "Idle connections.", nil, nil)
+	dbWaitCount       = prometheus.NewDesc(namespace+"_db_wait_count_total", "Times a request waited for a free connection.", nil, nil)
+	dbWaitDuration    = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total", "Time spent waiting for a free connection.", nil, nil)
+)
+
+type dbStatsCollector struct {
+	db *sql.DB
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenConnections
+	ch <- dbInUse
+	ch <- dbIdle
+	ch <- dbWaitCount
+	ch <- dbWaitDuration
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenConnections, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body, err := ioutil.ReadAll(recorder.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(body)
+	}
+
+	It("exposes request, login and signup counters", func() {
+		ObserveRPC("GetSession", "OK", 20*time.Millisecond)
+		ObserveLogin("failure", "wrong_password")
+		ObserveSignup("responder", "success")
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(`perscoreauth_grpc_requests_total{code="OK",method="GetSession"} 1`))
+		Expect(body).To(ContainSubstring(`perscoreauth_grpc_request_duration_seconds_bucket{code="OK",method="GetSession",le="0.025"} 1`))
+		Expect(body).To(ContainSubstring(`perscoreauth_logins_total{outcome="failure",reason="wrong_password"} 1`))
+		Expect(body).To(ContainSubstring(`perscoreauth_signups_total{outcome="success",role="responder"} 1`))
+	})
+
+	Describe("sessionTracker", func() {
+		It("only counts sessions that have not expired", func() {
+			now := time.Now()
+			tracker := &sessionTracker{}
+			tracker.add(now.Add(-time.Minute), now)
+			tracker.add(now.Add(2*time.Minute), now)
+			tracker.add(now.Add(time.Minute), now)
+
+			Expect(tracker.active(now)).To(Equal(2))
+			Expect(tracker.active(now.Add(90 * time.Second))).To(Equal(1))
+			Expect(tracker.expiry).To(HaveLen(1))
+		})
+
+		It("drops expired sessions without being scraped", func() {
+			now := time.Now()
+			tracker := &sessionTracker{}
+			for i := 0; i < 1000; i++ {
+				at := now.Add(time.Duration(i) * time.Second)
+				tracker.add(at.Add(time.Minute), at)
+			}
+			Expect(len(tracker.expiry)).To(BeNumerically("<=", 61))
+		})
+	})
+})
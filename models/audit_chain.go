@@ -0,0 +1,268 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditSigningKey signs audit checkpoints. Checkpoints are not written while
+// it is empty, which is logged once.
+var AuditSigningKey []byte
+
+// missingKeyWarning logs once that checkpoints are skipped
+var missingKeyWarning sync.Once
+
+// AuditCheckpointInterval is the number of events between two checkpoints
+var AuditCheckpointInterval uint64 = 100
+
+// auditAppendRetries bounds the retries when another process appended an
+// event to the same tenant chain concurrently
+const auditAppendRetries = 5
+
+// auditAppendMu serializes appends within the process outside units of
+// work. Appends from other processes and from units of work are caught by the
+// unique (tenant, seq) index and retried.
+var auditAppendMu sync.Mutex
+
+// AuditCheckpoint is a signed record of the chain head at Seq. Because it is
+// signed with a key that is not stored in the database, rewriting the whole
+// chain or dropping its newest events is detected by VerifyAuditChain.
+type AuditCheckpoint struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	Tenant    string `gorm:"unique_index:idx_audit_checkpoints_tenant_seq"`
+	Seq       uint64 `gorm:"unique_index:idx_audit_checkpoints_tenant_seq"`
+	Hash      string
+	Signature string
+}
+
+// ComputeHash returns the hash of the event contents and its link to the
+// previous event. The ID is left out since it is assigned by the database.
+func (event AuditEvent) ComputeHash() string {
+	h := sha256.New()
+	writeHashField := func(value string) {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(value)))
+		h.Write(length[:])
+		h.Write([]byte(value))
+	}
+	writeHashField(event.Tenant)
+	writeHashField(fmt.Sprint(event.Seq))
+	writeHashField(event.PrevHash)
+	writeHashField(event.CreatedAt.UTC().Format(time.RFC3339Nano))
+	writeHashField(event.Type)
+	writeHashField(event.Actor)
+	writeHashField(event.Subject)
+	writeHashField(event.PeerIP)
+	writeHashField(event.UserAgent)
+	writeHashField(event.Outcome)
+	writeHashField(event.Reason)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func checkpointSignature(key []byte, tenant string, seq uint64, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%d\n%s", tenant, seq, hash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// appendAuditEvent links event to the head of its tenant chain and inserts
+// it, writing a checkpoint every AuditCheckpointInterval events.
+func appendAuditEvent(db *gorm.DB, event *AuditEvent) error {
+	auditAppendMu.Lock()
+	defer auditAppendMu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < auditAppendRetries; attempt++ {
+		tx := db.Begin()
+		if tx.Error != nil {
+			return tx.Error
+		}
+		if err = appendAuditEventTx(tx, event); err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit().Error
+		}
+		if !isUniqueViolation(err) {
+			return err
+		}
+		event.ID = 0
+	}
+	return err
+}
+
+// appendAuditEventInTx appends event within the transaction tx of a unit of
+// work. Taking auditAppendMu for the transaction would run the units of work
+// of the process one at a time, so an append conflicting with a concurrent
+// one is rolled back to a savepoint and retried instead.
+func appendAuditEventInTx(tx *gorm.DB, event *AuditEvent) error {
+	var err error
+	for attempt := 0; attempt < auditAppendRetries; attempt++ {
+		if err = tx.Exec("SAVEPOINT audit_append").Error; err != nil {
+			return err
+		}
+		if err = appendAuditEventTx(tx, event); err == nil {
+			return tx.Exec("RELEASE SAVEPOINT audit_append").Error
+		}
+		if rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT audit_append").Error; rollbackErr != nil || !isUniqueViolation(err) {
+			return err
+		}
+		event.ID = 0
+	}
+	return err
+}
+
+// appendAuditEventTx appends event within tx. Callers hold auditAppendMu or
+// retry on unique violations of the (tenant, seq) index.
+func appendAuditEventTx(tx *gorm.DB, event *AuditEvent) error {
+	// The database keeps microseconds, so drop the rest before hashing
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	var head AuditEvent
+	query := tx.Where("tenant = ? AND seq > 0", event.Tenant).Order("seq desc").First(&head)
+	if query.Error != nil && !query.RecordNotFound() {
+		return query.Error
+	}
+
+	event.Seq = head.Seq + 1
+	event.PrevHash = head.Hash
+	event.Hash = event.ComputeHash()
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+
+	if AuditCheckpointInterval == 0 || event.Seq%AuditCheckpointInterval != 0 {
+		return nil
+	}
+	if len(AuditSigningKey) == 0 {
+		missingKeyWarning.Do(func() {
+			log.Warn("audit_signing_key is not set, audit checkpoints are not written and truncated or rewritten chains are not detected")
+		})
+		return nil
+	}
+	checkpoint := AuditCheckpoint{
+		Tenant:    event.Tenant,
+		Seq:       event.Seq,
+		Hash:      event.Hash,
+		Signature: checkpointSignature(AuditSigningKey, event.Tenant, event.Seq, event.Hash),
+	}
+	return tx.Create(&checkpoint).Error
+}
+
+func isUniqueViolation(err error) bool {
+	switch err := err.(type) {
+	case *pq.Error:
+		return err.Code == "23505"
+	case sqlite3.Error:
+		return err.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
+
+// AuditChainBreak describes the first problem found in a chain
+type AuditChainBreak struct {
+	Seq    uint64
+	Reason string
+}
+
+func (b AuditChainBreak) String() string {
+	return fmt.Sprintf("seq %d: %s", b.Seq, b.Reason)
+}
+
+// AuditChainReport is the result of verifying the chain of one tenant
+type AuditChainReport struct {
+	Tenant      string
+	Events      uint64
+	Checkpoints int
+	Break       *AuditChainBreak
+}
+
+// AuditTenants returns the tenants that have chained audit events
+func AuditTenants(db *gorm.DB) ([]string, error) {
+	var tenants []string
+	err := db.Model(&AuditEvent{}).Where("seq > 0").Order("tenant").Pluck("DISTINCT tenant", &tenants).Error
+	return tenants, err
+}
+
+// CountUnchainedAuditEvents returns the number of events recorded before the
+// hash chain was introduced, which VerifyAuditChain cannot cover.
+func CountUnchainedAuditEvents(db *gorm.DB) (int, error) {
+	var count int
+	err := db.Model(&AuditEvent{}).Where("seq IS NULL OR seq = 0").Count(&count).Error
+	return count, err
+}
+
+// VerifyAuditChain walks the chain of tenant from the first event and stops
+// at the first missing, reordered or modified event. Checkpoints are checked
+// against their signature when key is set, and against the event they refer to.
+func VerifyAuditChain(db *gorm.DB, tenant string, key []byte) (AuditChainReport, error) {
+	report := AuditChainReport{Tenant: tenant}
+
+	var checkpoints []AuditCheckpoint
+	if err := db.Where("tenant = ?", tenant).Order("seq").Find(&checkpoints).Error; err != nil {
+		return report, err
+	}
+	report.Checkpoints = len(checkpoints)
+	checkpointsBySeq := map[uint64]AuditCheckpoint{}
+	for _, checkpoint := range checkpoints {
+		if len(key) > 0 && !hmac.Equal([]byte(checkpoint.Signature), []byte(checkpointSignature(key, tenant, checkpoint.Seq, checkpoint.Hash))) {
+			report.Break = &AuditChainBreak{Seq: checkpoint.Seq, Reason: "checkpoint signature is invalid"}
+			return report, nil
+		}
+		checkpointsBySeq[checkpoint.Seq] = checkpoint
+	}
+
+	const batchSize = 1000
+	prevHash := ""
+	expected := uint64(1)
+	for {
+		var events []AuditEvent
+		err := db.Where("tenant = ? AND seq >= ?", tenant, expected).Order("seq").Limit(batchSize).Find(&events).Error
+		if err != nil {
+			return report, err
+		}
+
+		for _, event := range events {
+			switch {
+			case event.Seq != expected:
+				report.Break = &AuditChainBreak{Seq: expected, Reason: "event is missing"}
+			case event.PrevHash != prevHash:
+				report.Break = &AuditChainBreak{Seq: event.Seq, Reason: "link to the previous event does not match"}
+			case event.ComputeHash() != event.Hash:
+				report.Break = &AuditChainBreak{Seq: event.Seq, Reason: "event contents do not match its hash"}
+			}
+			if checkpoint, ok := checkpointsBySeq[event.Seq]; ok && report.Break == nil && checkpoint.Hash != event.Hash {
+				report.Break = &AuditChainBreak{Seq: event.Seq, Reason: "event does not match its signed checkpoint"}
+			}
+			if report.Break != nil {
+				return report, nil
+			}
+
+			report.Events++
+			prevHash = event.Hash
+			expected++
+		}
+
+		if len(events) < batchSize {
+			break
+		}
+	}
+
+	// A checkpoint past the last event means the newest events were removed
+	if len(checkpoints) > 0 {
+		if last := checkpoints[len(checkpoints)-1]; last.Seq >= expected {
+			report.Break = &AuditChainBreak{Seq: expected, Reason: fmt.Sprintf("event is missing, checkpoint %d exists", last.Seq)}
+		}
+	}
+	return report, nil
+}
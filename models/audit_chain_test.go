@@ -0,0 +1,52 @@
+package models_test
+
+import (
+	"time"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuditChain", func() {
+	Describe("ComputeHash", func() {
+		event := models.AuditEvent{
+			Tenant:    models.DefaultTenant,
+			Seq:       2,
+			PrevHash:  "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b",
+			CreatedAt: time.Date(2017, 10, 21, 4, 39, 52, 123456000, time.UTC),
+			Type:      models.AuditLoginFailure,
+			Actor:     "perScoreServer",
+			Subject:   "jane@example.com",
+			Outcome:   models.AuditFailure,
+			Reason:    "wrong_password",
+		}
+
+		It("does not depend on the time zone or the database ID", func() {
+			other := event
+			other.ID = 42
+			other.CreatedAt = event.CreatedAt.In(time.FixedZone("IST", 19800))
+			Expect(other.ComputeHash()).To(Equal(event.ComputeHash()))
+		})
+
+		It("changes when the event is modified", func() {
+			tampered := event
+			tampered.Outcome = models.AuditSuccess
+			Expect(tampered.ComputeHash()).NotTo(Equal(event.ComputeHash()))
+		})
+
+		It("changes when the event is linked elsewhere", func() {
+			relinked := event
+			relinked.PrevHash = ""
+			Expect(relinked.ComputeHash()).NotTo(Equal(event.ComputeHash()))
+		})
+
+		It("keeps field boundaries", func() {
+			shifted := event
+			shifted.Actor = "perScoreServerjane@example.com"
+			shifted.Subject = ""
+			Expect(shifted.ComputeHash()).NotTo(Equal(event.ComputeHash()))
+		})
+	})
+})
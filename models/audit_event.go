@@ -0,0 +1,141 @@
+package models
+
+import (
+	"context"
+	"perScoreAuth/metrics"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Audit event types
+const (
+	AuditSignup                 = "signup"
+	AuditLoginSuccess           = "login_success"
+	AuditLoginFailure           = "login_failure"
+	AuditLoginBlocked           = "login_blocked" // right password, which must be changed first
+	AuditLockout                = "lockout"
+	AuditPasswordChange         = "password_change"
+	AuditPasswordChangeRequired = "password_change_required"
+	AuditRoleChange             = "role_change"
+	AuditTokenRevocation        = "token_revocation"
+	AuditAccountDisabled        = "account_disabled"
+)
+
+// Audit event outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a security relevant action. Events are only ever inserted,
+// a trigger rejects updates and deletes on Postgres. Each event is chained to
+// the previous event of its tenant through PrevHash, see audit_chain.go.
+type AuditEvent struct {
+	ID        uint      `gorm:"primary_key"`
+	CreatedAt time.Time `gorm:"index"`
+	Tenant    string    `gorm:"unique_index:idx_audit_events_tenant_seq"`
+	Seq       uint64    `gorm:"unique_index:idx_audit_events_tenant_seq"`
+	Type      string    `gorm:"index"`
+	Actor     string
+	Subject   string `gorm:"index"`
+	PeerIP    string
+	UserAgent string
+	Outcome   string
+	Reason    string
+	PrevHash  string
+	Hash      string
+}
+
+// AuditEventFilter selects the events returned by ListAuditEvents. Zero
+// values match everything.
+type AuditEventFilter struct {
+	Tenant  string
+	Subject string
+	Type    string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+// DefaultAuditEventLimit is the number of events returned when no limit is given
+const DefaultAuditEventLimit = 100
+
+// MaxAuditEventLimit caps the number of events returned in one call
+const MaxAuditEventLimit = 1000
+
+// DefaultTenant is used for requests that do not name a tenant
+const DefaultTenant = "default"
+
+// AuditContext describes where a request came from
+type AuditContext struct {
+	Tenant    string
+	Actor     string
+	PeerIP    string
+	UserAgent string
+}
+
+type auditContextKey struct{}
+
+// WithAuditContext returns a context carrying the request origin recorded
+// with audit events.
+func WithAuditContext(ctx context.Context, ac AuditContext) context.Context {
+	return context.WithValue(ctx, auditContextKey{}, ac)
+}
+
+// AuditContextFrom returns the request origin stored in ctx
+func AuditContextFrom(ctx context.Context) AuditContext {
+	ac, _ := ctx.Value(auditContextKey{}).(AuditContext)
+	return ac
+}
+
+// RecordAuditEvent appends an event for subject, taking the actor, peer and
+// user agent from ctx. Failures are logged rather than returned so auditing
+// never breaks the action being audited.
+func RecordAuditEvent(ctx context.Context, audit AuditStore, eventType, subject, outcome, reason string) {
+	event := newAuditEvent(ctx, eventType, subject, outcome, reason)
+	if err := audit.AppendAuditEvent(&event); err != nil {
+		log.Errorf("Error recording %s audit event: %+v", eventType, err)
+	}
+
+	switch eventType {
+	case AuditLoginSuccess, AuditLoginFailure:
+		metrics.ObserveLogin(outcome, reason)
+	case AuditLockout:
+		metrics.ObserveLockout()
+	}
+}
+
+// newAuditEvent returns the event RecordAuditEvent appends
+func newAuditEvent(ctx context.Context, eventType, subject, outcome, reason string) AuditEvent {
+	ac := AuditContextFrom(ctx)
+	event := AuditEvent{
+		Tenant:    ac.Tenant,
+		Type:      eventType,
+		Actor:     ac.Actor,
+		Subject:   subject,
+		PeerIP:    ac.PeerIP,
+		UserAgent: ac.UserAgent,
+		Outcome:   outcome,
+		Reason:    reason,
+	}
+	if event.Tenant == "" {
+		event.Tenant = DefaultTenant
+	}
+	if event.Actor == "" {
+		event.Actor = subject
+	}
+	return event
+}
+
+// limit returns the number of events to return for the filter
+func (filter AuditEventFilter) limit() int {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditEventLimit
+	}
+	if limit > MaxAuditEventLimit {
+		limit = MaxAuditEventLimit
+	}
+	return limit
+}
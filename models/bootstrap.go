@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// BootstrapDatabase creates the login role and the database described by
+// config when they are missing, connected to Postgres as an administrator.
+// Existing roles and databases are left untouched. It returns what it did.
+func BootstrapDatabase(admin *gorm.DB, config DatabaseConfig) ([]string, error) {
+	if config.Name == "" || config.Username == "" {
+		return nil, fmt.Errorf("the database name and username must be set")
+	}
+
+	var actions []string
+	exists, err := rowExists(admin, "SELECT 1 FROM pg_roles WHERE rolname = ?", config.Username)
+	if err != nil {
+		return actions, err
+	}
+	if exists {
+		actions = append(actions, fmt.Sprintf("role %s already exists", config.Username))
+	} else {
+		password, err := quoteLiteral(config.Password)
+		if err != nil {
+			return actions, fmt.Errorf("the password cannot be used: %v", err)
+		}
+		statement := fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", pq.QuoteIdentifier(config.Username), password)
+		if _, err := admin.CommonDB().Exec(statement); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("created role %s", config.Username))
+	}
+
+	exists, err = rowExists(admin, "SELECT 1 FROM pg_database WHERE datname = ?", config.Name)
+	if err != nil {
+		return actions, err
+	}
+	if exists {
+		actions = append(actions, fmt.Sprintf("database %s already exists", config.Name))
+	} else {
+		// CREATE DATABASE cannot run in a transaction, so run it on its own
+		statement := fmt.Sprintf("CREATE DATABASE %s WITH OWNER %s", pq.QuoteIdentifier(config.Name), pq.QuoteIdentifier(config.Username))
+		if _, err := admin.CommonDB().Exec(statement); err != nil {
+			return actions, err
+		}
+		actions = append(actions, fmt.Sprintf("created database %s owned by %s", config.Name, config.Username))
+	}
+	return actions, nil
+}
+
+func rowExists(db *gorm.DB, query string, args ...interface{}) (bool, error) {
+	var count int
+	rows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		count++
+	}
+	return count > 0, rows.Err()
+}
+
+// quoteLiteral quotes a string for use as a literal in statements that do
+// not accept parameters, such as CREATE ROLE. Postgres strings cannot hold
+// NUL bytes, so literals with one are refused.
+func quoteLiteral(literal string) (string, error) {
+	if strings.ContainsRune(literal, 0) {
+		return "", fmt.Errorf("contains a NUL byte")
+	}
+	literal = strings.Replace(literal, `'`, `''`, -1)
+	if strings.Contains(literal, `\`) {
+		return `E'` + strings.Replace(literal, `\`, `\\`, -1) + `'`, nil
+	}
+	return `'` + literal + `'`, nil
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BreachedPasswords is the corpus checked by policies rejecting breached
+// passwords, see OpenBreachCorpus
+var BreachedPasswords *BreachCorpus
+
+// breachHashLength is the length of a hex encoded SHA-1 hash
+const breachHashLength = 2 * sha1.Size
+
+// breachPrefixLength is the length of the hash prefix naming a range
+const breachPrefixLength = 5
+
+// BreachCorpus looks up how often passwords appear in breaches, offline. It
+// reads the Have I Been Pwned password downloads in either of their formats:
+// a directory of range files, each named after the first five characters of
+// the SHA-1 hashes it holds ("21BD1" or "21BD1.txt") and holding sorted
+// "<rest of the hash>:<count>" lines, or a single file of
+// "<SHA-1 in hex>:<count>" lines sorted by hash. Files are memory-mapped where
+// the platform allows, so only the pages a lookup touches are read.
+type BreachCorpus struct {
+	// dir is the directory of range files, empty for a single file
+	dir   string
+	data  []byte
+	close func() error
+}
+
+// OpenBreachCorpus opens the corpus at path, a directory of range files or a
+// file of sorted hashes
+func OpenBreachCorpus(path string) (*BreachCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &BreachCorpus{dir: path}, nil
+	}
+	data, unmap, err := mapFile(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("mapping %s: %v", path, err)
+	}
+	return &BreachCorpus{data: data, close: unmap}, nil
+}
+
+// Count returns how often password appears in the corpus, 0 when it does
+// not. A nil corpus contains nothing.
+func (c *BreachCorpus) Count(password string) int {
+	if c == nil {
+		return 0
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := make([]byte, breachHashLength)
+	hex.Encode(hash, sum[:])
+	hash = bytes.ToUpper(hash)
+
+	if c.dir == "" {
+		return searchBreachLines(c.data, hash)
+	}
+	return c.countInRange(hash)
+}
+
+// countInRange maps the range file of hash and searches it for the rest of
+// hash. Ranges without a file contain nothing.
+func (c *BreachCorpus) countInRange(hash []byte) int {
+	prefix := string(hash[:breachPrefixLength])
+	var f *os.File
+	var err error
+	for _, name := range []string{prefix + ".txt", prefix} {
+		if f, err = os.Open(filepath.Join(c.dir, name)); !os.IsNotExist(err) {
+			break
+		}
+	}
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"range": prefix, "error": err}).Error("Opening breach corpus range failed")
+		return 0
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.WithFields(log.Fields{"range": prefix, "error": err}).Error("Opening breach corpus range failed")
+		return 0
+	}
+	data, unmap, err := mapFile(f, info.Size())
+	if err != nil {
+		log.WithFields(log.Fields{"range": prefix, "error": err}).Error("Mapping breach corpus range failed")
+		return 0
+	}
+	defer unmap()
+	return searchBreachLines(data, hash[breachPrefixLength:])
+}
+
+// searchBreachLines binary searches the sorted lines of data for the one
+// starting with hash and returns its count
+func searchBreachLines(data, hash []byte) int {
+	// lo always is the start of a line
+	lo, hi := 0, len(data)
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start := bytes.LastIndexByte(data[lo:mid], '\n') + 1 + lo
+		end := bytes.IndexByte(data[start:hi], '\n')
+		if end < 0 {
+			end = hi
+		} else {
+			end += start
+		}
+
+		line := data[start:end]
+		switch cmp := compareBreachHash(line, hash); {
+		case cmp == 0:
+			return breachCount(line[len(hash):])
+		case cmp < 0:
+			lo = end + 1
+		default:
+			hi = start
+		}
+	}
+	return 0
+}
+
+// Close unmaps the corpus
+func (c *BreachCorpus) Close() error {
+	if c == nil || c.close == nil {
+		return nil
+	}
+	err := c.close()
+	c.data, c.close = nil, nil
+	return err
+}
+
+// compareBreachHash compares the hash line starts with to hash, ignoring
+// the case of the hex digits
+func compareBreachHash(line, hash []byte) int {
+	for i := range hash {
+		if i == len(line) {
+			return -1
+		}
+		b := line[i]
+		if 'a' <= b && b <= 'f' {
+			b -= 'a' - 'A'
+		}
+		if b != hash[i] {
+			if b < hash[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// breachCount parses the count following the hash of a line. Lines without
+// one count once.
+func breachCount(line []byte) int {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 || line[0] != ':' {
+		return 1
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
+}
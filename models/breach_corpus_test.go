@@ -0,0 +1,158 @@
+package models_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BreachCorpus", func() {
+	var (
+		dir    string
+		corpus *models.BreachCorpus
+	)
+
+	hash := func(password string) string {
+		sum := sha1.Sum([]byte(password))
+		return strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+
+	// open writes a corpus with the count of every password, sorted by hash
+	open := func(counts map[string]int, newline string) *models.BreachCorpus {
+		var lines []string
+		for password, count := range counts {
+			lines = append(lines, fmt.Sprintf("%s:%d", hash(password), count))
+		}
+		for i := 0; i < 500; i++ {
+			lines = append(lines, fmt.Sprintf("%s:%d", hash(fmt.Sprintf("filler-%d", i)), i+1))
+		}
+		sort.Strings(lines)
+		path := filepath.Join(dir, "pwned.txt")
+		Expect(ioutil.WriteFile(path, []byte(strings.Join(lines, newline)+newline), 0600)).To(Succeed())
+
+		var err error
+		corpus, err = models.OpenBreachCorpus(path)
+		Expect(err).NotTo(HaveOccurred())
+		return corpus
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "breach")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(corpus.Close()).To(Succeed())
+		os.RemoveAll(dir)
+	})
+
+	It("counts breached passwords", func() {
+		open(map[string]int{"password": 3861493, "hunter2": 17}, "\n")
+		Expect(corpus.Count("password")).To(Equal(3861493))
+		Expect(corpus.Count("hunter2")).To(Equal(17))
+		for i := 0; i < 500; i++ {
+			Expect(corpus.Count(fmt.Sprintf("filler-%d", i))).To(Equal(i + 1))
+		}
+		Expect(corpus.Count("s3cret-Passw0rd")).To(BeZero())
+	})
+
+	It("reads CRLF lines and lowercase hashes", func() {
+		open(map[string]int{"hunter2": 17}, "\r\n")
+		Expect(corpus.Count("hunter2")).To(Equal(17))
+
+		path := filepath.Join(dir, "lower.txt")
+		Expect(ioutil.WriteFile(path, []byte(strings.ToLower(hash("hunter2"))+":5"), 0600)).To(Succeed())
+		lower, err := models.OpenBreachCorpus(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer lower.Close()
+		Expect(lower.Count("hunter2")).To(Equal(5))
+	})
+
+	It("contains nothing when empty or nil", func() {
+		path := filepath.Join(dir, "empty.txt")
+		Expect(ioutil.WriteFile(path, nil, 0600)).To(Succeed())
+		var err error
+		corpus, err = models.OpenBreachCorpus(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(corpus.Count("password")).To(BeZero())
+
+		var none *models.BreachCorpus
+		Expect(none.Count("password")).To(BeZero())
+	})
+
+	// openRanges writes a directory of range files with the count of every
+	// password, named like the HIBP downloader names them
+	openRanges := func(counts map[string]int, extension string) *models.BreachCorpus {
+		ranges := map[string][]string{}
+		for i := 0; i < 500; i++ {
+			counts[fmt.Sprintf("filler-%d", i)] = i + 1
+		}
+		for password, count := range counts {
+			h := hash(password)
+			ranges[h[:5]] = append(ranges[h[:5]], fmt.Sprintf("%s:%d", h[5:], count))
+		}
+		for prefix, lines := range ranges {
+			sort.Strings(lines)
+			path := filepath.Join(dir, prefix+extension)
+			Expect(ioutil.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600)).To(Succeed())
+		}
+
+		var err error
+		corpus, err = models.OpenBreachCorpus(dir)
+		Expect(err).NotTo(HaveOccurred())
+		return corpus
+	}
+
+	It("counts breached passwords in a directory of ranges", func() {
+		for _, extension := range []string{".txt", ""} {
+			openRanges(map[string]int{"password": 3861493, "hunter2": 17}, extension)
+			Expect(corpus.Count("password")).To(Equal(3861493))
+			Expect(corpus.Count("hunter2")).To(Equal(17))
+			for i := 0; i < 500; i++ {
+				Expect(corpus.Count(fmt.Sprintf("filler-%d", i))).To(Equal(i + 1))
+			}
+			Expect(corpus.Close()).To(Succeed())
+			Expect(os.RemoveAll(dir)).To(Succeed())
+			Expect(os.Mkdir(dir, 0700)).To(Succeed())
+		}
+	})
+
+	It("contains nothing in ranges without a file", func() {
+		openRanges(map[string]int{"hunter2": 17}, ".txt")
+		Expect(os.Remove(filepath.Join(dir, hash("hunter2")[:5]+".txt"))).To(Succeed())
+		Expect(corpus.Count("hunter2")).To(BeZero())
+
+		// A range with a file but not the hash
+		h := hash("s3cret-Passw0rd")
+		Expect(ioutil.WriteFile(filepath.Join(dir, h[:5]+".txt"), []byte("0000000000000000000000000000000000A:3\r\n"), 0600)).To(Succeed())
+		Expect(corpus.Count("s3cret-Passw0rd")).To(BeZero())
+	})
+
+	It("fails on a missing corpus", func() {
+		_, err := models.OpenBreachCorpus(filepath.Join(dir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("is checked by password policies", func() {
+		breached := models.BreachedPasswords
+		defer func() { models.BreachedPasswords = breached }()
+		models.BreachedPasswords = open(map[string]int{"correct horse": 2}, "\n")
+
+		policy := models.PasswordPolicy{RejectBreached: true}
+		Expect(policy.Check("correct horse", models.PasswordOwner{})).To(HaveLen(1))
+		Expect(policy.Check("correct horse", models.PasswordOwner{})[0].Rule).To(Equal("Breached"))
+		policy.MaxBreachCount = 2
+		Expect(policy.Check("correct horse", models.PasswordOwner{})).To(BeEmpty())
+	})
+})
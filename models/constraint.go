@@ -0,0 +1,83 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ConstraintError is a database constraint a write violated, blamed on the
+// field of the request holding the offending value
+type ConstraintError struct {
+	// Field is the snake cased name of the field, as in field responses
+	Field string
+	// Validation is Taken, Required, Max or Invalid
+	Validation string
+	// Err is the error of the database driver
+	Err error
+}
+
+// ErrEmailTaken is returned by CreateUser when another user has the email
+var ErrEmailTaken = &ConstraintError{Field: "email", Validation: "Taken"}
+
+var constraintDescriptions = map[string]string{
+	"Taken":    "is already taken",
+	"Required": "is required",
+	"Max":      "is too long",
+	"Invalid":  "is invalid",
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Field + " " + constraintDescriptions[e.Validation]
+}
+
+// pqKeyDetail extracts the column from the detail of a Postgres unique
+// violation, such as "Key (email)=(ada@example.com) already exists."
+var pqKeyDetail = regexp.MustCompile(`^Key \(([a-z_]+)\)=`)
+
+// ConstraintViolation returns err as a *ConstraintError when it is a
+// constraint violation of Postgres or SQLite on a single column, ErrEmailTaken
+// for a taken email, and err itself otherwise
+func ConstraintViolation(err error) error {
+	var column, validation string
+	switch e := err.(type) {
+	case *pq.Error:
+		column = e.Column
+		switch e.Code.Name() {
+		case "unique_violation":
+			validation = "Taken"
+			if m := pqKeyDetail.FindStringSubmatch(e.Detail); m != nil {
+				column = m[1]
+			}
+		case "not_null_violation":
+			validation = "Required"
+		case "string_data_right_truncation":
+			validation = "Max"
+		case "check_violation", "foreign_key_violation", "invalid_text_representation":
+			validation = "Invalid"
+		}
+	case sqlite3.Error:
+		// SQLite names the column in the message: "UNIQUE constraint failed: users.email"
+		if i := strings.LastIndex(e.Error(), "."); i >= 0 && strings.Contains(e.Error(), "constraint failed: ") {
+			column = e.Error()[i+1:]
+		}
+		switch e.ExtendedCode {
+		case sqlite3.ErrConstraintUnique:
+			validation = "Taken"
+		case sqlite3.ErrConstraintNotNull:
+			validation = "Required"
+		case sqlite3.ErrConstraintCheck, sqlite3.ErrConstraintForeignKey:
+			validation = "Invalid"
+		}
+	}
+
+	switch {
+	case validation == "" || column == "":
+		return err
+	case column == "email" && validation == "Taken":
+		return ErrEmailTaken
+	}
+	return &ConstraintError{Field: column, Validation: validation, Err: err}
+}
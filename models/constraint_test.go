@@ -0,0 +1,38 @@
+package models_test
+
+import (
+	"errors"
+
+	"perScoreAuth/models"
+
+	"github.com/lib/pq"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConstraintViolation", func() {
+	It("maps a taken email to ErrEmailTaken", func() {
+		err := &pq.Error{Code: "23505", Constraint: "users_email_key", Detail: "Key (email)=(ada@example.com) already exists."}
+		Expect(models.ConstraintViolation(err)).To(BeIdenticalTo(models.ErrEmailTaken))
+		Expect(models.ErrEmailTaken.Error()).To(Equal("email is already taken"))
+	})
+
+	It("blames other violations on their column", func() {
+		err := &pq.Error{Code: "23502", Column: "city"}
+		Expect(models.ConstraintViolation(err)).To(Equal(&models.ConstraintError{Field: "city", Validation: "Required", Err: err}))
+
+		err = &pq.Error{Code: "22001", Column: "first_name"}
+		Expect(models.ConstraintViolation(err)).To(MatchError("first_name is too long"))
+	})
+
+	It("returns other errors unchanged", func() {
+		other := errors.New("connection reset")
+		Expect(models.ConstraintViolation(other)).To(Equal(other))
+		Expect(models.ConstraintViolation(nil)).To(BeNil())
+
+		err := &pq.Error{Code: "23505", Constraint: "audit_events_tenant_seq_key"}
+		Expect(models.ConstraintViolation(err)).To(BeIdenticalTo(err))
+		err = &pq.Error{Code: "40001"}
+		Expect(models.ConstraintViolation(err)).To(BeIdenticalTo(err))
+	})
+})
@@ -1,23 +1,110 @@
 package models
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres" // postgres dialect for gorm
+	_ "github.com/jinzhu/gorm/dialects/sqlite"   // sqlite3 dialect for gorm
+)
+
+// Supported database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite3"
 )
 
+// sqliteBusyTimeout is how long SQLite waits for another process to finish
+// writing, in milliseconds
+const sqliteBusyTimeout = 5000
+
 var (
 	db *gorm.DB
 )
 
-// SetupDatabase - Creates the tables in the database
-func SetupDatabase(db *gorm.DB) error {
-	err := db.AutoMigrate(
-		&User{},
-		&Location{},
-	).Error
+// DatabaseConfig describes how to connect to a database
+type DatabaseConfig struct {
+	Driver   string
+	Host     string
+	Name     string
+	Username string
+	Password string
+	SSLMode  string
+}
+
+// DatabaseConfigFor reads the configuration of an environment, such as "dev"
+// or "test", from the <ENV>_DB_DRIVER, <ENV>_HOST, ... variables. With the
+// sqlite3 driver only <ENV>_DBNAME is used, as the path of the database file.
+func DatabaseConfigFor(env string) DatabaseConfig {
+	prefix := strings.ToUpper(env) + "_"
+	driver := strings.ToLower(os.Getenv(prefix + "DB_DRIVER"))
+	if driver == "sqlite" {
+		driver = DriverSQLite
+	}
+	return DatabaseConfig{
+		Driver:   driver,
+		Host:     os.Getenv(prefix + "HOST"),
+		Name:     os.Getenv(prefix + "DBNAME"),
+		Username: os.Getenv(prefix + "USERNAME"),
+		Password: os.Getenv(prefix + "PASSWORD"),
+		SSLMode:  os.Getenv(prefix + "SSLMODE"),
+	}
+}
+
+// ConnectionString returns the connection string of the database
+func (c DatabaseConfig) ConnectionString() string {
+	if c.Driver == DriverSQLite {
+		if strings.Contains(c.Name, "?") {
+			return c.Name
+		}
+		return fmt.Sprintf("%s?_busy_timeout=%d", c.Name, sqliteBusyTimeout)
+	}
+	return fmt.Sprintf("host=%s dbname=%s user=%s password=%s sslmode=%s", c.Host, c.Name, c.Username, c.Password, c.SSLMode)
+}
+
+// Open connects to the database
+func (c DatabaseConfig) Open() (*gorm.DB, error) {
+	switch c.Driver {
+	case DriverPostgres:
+	case DriverSQLite:
+		if c.Name == "" {
+			return nil, fmt.Errorf("the path of the SQLite database must be set")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, expected %s or %s", c.Driver, DriverPostgres, DriverSQLite)
+	}
+
+	db, err := gorm.Open(c.Driver, c.ConnectionString())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if c.Driver == DriverSQLite {
+		// SQLite has a single writer, one connection avoids "database is
+		// locked" errors within the process and lets :memory: databases
+		// be shared by all queries
+		db.DB().SetMaxOpenConns(1)
 	}
+	return db, nil
+}
 
+// OpenDatabase connects to the database of an environment, see DatabaseConfigFor
+func OpenDatabase(env string) (*gorm.DB, error) {
+	return DatabaseConfigFor(env).Open()
+}
+
+// SetupDatabase applies the pending migrations, see MigrateUp
+func SetupDatabase(db *gorm.DB) error {
+	_, err := MigrateUp(db, 0)
 	return err
 }
+
+// PingDatabase checks that the database answers within timeout
+func PingDatabase(db *gorm.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return db.DB().PingContext(ctx)
+}
@@ -0,0 +1,69 @@
+package models_test
+
+import (
+	"os"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DatabaseConfig", func() {
+	BeforeEach(func() {
+		os.Setenv("CI_DB_DRIVER", "postgres")
+		os.Setenv("CI_HOST", "db.internal")
+		os.Setenv("CI_DBNAME", "ci_per_score_auth")
+		os.Setenv("CI_USERNAME", "ciperscoreauth")
+		os.Setenv("CI_PASSWORD", "s3cret")
+		os.Setenv("CI_SSLMODE", "require")
+	})
+
+	AfterEach(func() {
+		for _, name := range []string{"DB_DRIVER", "HOST", "DBNAME", "USERNAME", "PASSWORD", "SSLMODE"} {
+			os.Unsetenv("CI_" + name)
+		}
+	})
+
+	It("reads the environment variables of an environment", func() {
+		config := models.DatabaseConfigFor("ci")
+		Expect(config).To(Equal(models.DatabaseConfig{
+			Driver:   "postgres",
+			Host:     "db.internal",
+			Name:     "ci_per_score_auth",
+			Username: "ciperscoreauth",
+			Password: "s3cret",
+			SSLMode:  "require",
+		}))
+		Expect(config.ConnectionString()).To(Equal("host=db.internal dbname=ci_per_score_auth user=ciperscoreauth password=s3cret sslmode=require"))
+	})
+
+	It("needs a database name and username to bootstrap", func() {
+		_, err := models.BootstrapDatabase(nil, models.DatabaseConfig{Driver: "postgres"})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("QuoteLiteral", func() {
+	It("quotes strings as SQL literals", func() {
+		for literal, quoted := range map[string]string{
+			"s3cret":         `'s3cret'`,
+			"":               `''`,
+			"it's":           `'it''s'`,
+			"'; DROP ROLE x": `'''; DROP ROLE x'`,
+			`back\slash`:     `E'back\\slash'`,
+			`\'`:             `E'\\'''`,
+			"über-geheim ✓":  `'über-geheim ✓'`,
+			`C:\temp's \\ x`: `E'C:\\temp''s \\\\ x'`,
+		} {
+			Expect(models.QuoteLiteral(literal)).To(Equal(quoted), literal)
+		}
+	})
+
+	It("refuses NUL bytes", func() {
+		for _, literal := range []string{"\x00", "s3cret\x00", `\` + "\x00'"} {
+			_, err := models.QuoteLiteral(literal)
+			Expect(err).To(MatchError(ContainSubstring("NUL")), literal)
+		}
+	})
+})
@@ -0,0 +1,4 @@
+package models
+
+// QuoteLiteral exposes quoteLiteral to the tests
+var QuoteLiteral = quoteLiteral
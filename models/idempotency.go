@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is a request made with an idempotency key and, once it
+// succeeded, its response
+type IdempotencyRecord struct {
+	ID     uint `gorm:"primary_key"`
+	Tenant string
+	Key    string
+	// Method is the full name of the RPC
+	Method string
+	// Fingerprint identifies the request, a key may not be reused for
+	// another one
+	Fingerprint string
+	// Response is the encoded response, nil while the request runs
+	Response []byte
+	// LockedUntil is when the lease of the request running without a
+	// response ends. A request that crashed holds the key until then, after
+	// which a retry takes it over.
+	LockedUntil time.Time
+	CreatedAt   time.Time
+	// ExpiresAt is when the key may be used for another request
+	ExpiresAt time.Time
+}
+
+// TableName keeps gorm from naming the table after the type
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,369 @@
+package models
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps users, audit and outbox events and idempotency records in
+// memory. It is safe for concurrent use and meant for tests and development.
+// Audit checkpoints are not written.
+type MemoryStore struct {
+	mu sync.Mutex
+	memoryData
+}
+
+// memoryData is the contents of a MemoryStore
+type memoryData struct {
+	users         []User
+	history       []PasswordHistory
+	historyID     uint
+	events        []AuditEvent
+	outbox        []OutboxEvent
+	idempotency   []IdempotencyRecord
+	idempotencyID uint
+}
+
+// clone returns a copy of d sharing no slices with it
+func (d memoryData) clone() memoryData {
+	d.users = append([]User(nil), d.users...)
+	d.history = append([]PasswordHistory(nil), d.history...)
+	d.events = append([]AuditEvent(nil), d.events...)
+	d.outbox = append([]OutboxEvent(nil), d.outbox...)
+	d.idempotency = append([]IdempotencyRecord(nil), d.idempotency...)
+	return d
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// CreateUser ...
+func (s *MemoryStore) CreateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	now := time.Now().UTC()
+	user.ID = uint(len(s.users) + 1)
+	user.CreatedAt, user.UpdatedAt = now, now
+	user.Location.ID = user.ID
+	user.Location.UserID = user.ID
+	user.Location.CreatedAt, user.Location.UpdatedAt = now, now
+	s.users = append(s.users, copyUser(*user))
+	return nil
+}
+
+// FindUserByEmail ...
+func (s *MemoryStore) FindUserByEmail(email string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return copyUser(user), nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+// ListUsers ...
+func (s *MemoryStore) ListUsers(filter UserFilter) ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []User
+	for _, user := range s.users {
+		if filter.Limit > 0 && len(users) == filter.Limit {
+			break
+		}
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if len(filter.Emails) > 0 && !containsString(filter.Emails, user.Email) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		if !filter.IncludeDisabled && user.DisabledAt != nil {
+			continue
+		}
+		users = append(users, copyUser(user))
+	}
+	return users, nil
+}
+
+// UpdatePassword ...
+func (s *MemoryStore) UpdatePassword(id uint, password string) error {
+	return s.updateUser(id, func(user *User) { user.Password = password })
+}
+
+// ChangePassword ...
+func (s *MemoryStore) ChangePassword(id uint, password string, changedAt time.Time) error {
+	changedAt = changedAt.UTC()
+	return s.updateUser(id, func(user *User) {
+		user.Password = password
+		user.PasswordChangedAt = &changedAt
+		user.MustChangePassword = false
+	})
+}
+
+// UpdateRole ...
+func (s *MemoryStore) UpdateRole(id uint, role string) error {
+	return s.updateUser(id, func(user *User) { user.Role = role })
+}
+
+// UpdateDisabledAt ...
+func (s *MemoryStore) UpdateDisabledAt(id uint, disabledAt time.Time) error {
+	return s.updateUser(id, func(user *User) { user.DisabledAt = &disabledAt })
+}
+
+func (s *MemoryStore) updateUser(id uint, update func(*User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == 0 || int(id) > len(s.users) {
+		return ErrUserNotFound
+	}
+	user := &s.users[id-1]
+	update(user)
+	user.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// UpdateMustChangePassword ...
+func (s *MemoryStore) UpdateMustChangePassword(id uint, must bool) error {
+	return s.updateUser(id, func(user *User) { user.MustChangePassword = must })
+}
+
+// AddPasswordHistory ...
+func (s *MemoryStore) AddPasswordHistory(id uint, hash string, keep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.historyID++
+	s.history = append(s.history, PasswordHistory{
+		ID:        s.historyID,
+		UserID:    id,
+		Password:  hash,
+		CreatedAt: time.Now().UTC(),
+	})
+
+	// Drop the oldest entries of the user beyond the keep newest
+	excess := -keep
+	for _, entry := range s.history {
+		if entry.UserID == id {
+			excess++
+		}
+	}
+	kept := s.history[:0]
+	for _, entry := range s.history {
+		if entry.UserID == id && excess > 0 {
+			excess--
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	s.history = kept
+	return nil
+}
+
+// PasswordHistory ...
+func (s *MemoryStore) PasswordHistory(id uint, limit int) ([]PasswordHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var entries []PasswordHistory
+	for i := len(s.history) - 1; i >= 0 && len(entries) < limit; i-- {
+		if s.history[i].UserID == id {
+			entries = append(entries, s.history[i])
+		}
+	}
+	return entries, nil
+}
+
+// AppendAuditEvent ...
+func (s *MemoryStore) AppendAuditEvent(event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var head AuditEvent
+	for _, existing := range s.events {
+		if existing.Tenant == event.Tenant {
+			head = existing
+		}
+	}
+	event.ID = uint(len(s.events) + 1)
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.Seq = head.Seq + 1
+	event.PrevHash = head.Hash
+	event.Hash = event.ComputeHash()
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// ListAuditEvents ...
+func (s *MemoryStore) ListAuditEvents(filter AuditEventFilter) ([]AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []AuditEvent
+	for _, event := range s.events {
+		switch {
+		case filter.Tenant != "" && event.Tenant != filter.Tenant,
+			filter.Subject != "" && event.Subject != filter.Subject,
+			filter.Type != "" && event.Type != filter.Type,
+			!filter.From.IsZero() && event.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !event.CreatedAt.Before(filter.To):
+			continue
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if limit := filter.limit(); len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// InTransaction calls fn with a copy of the store, which replaces the
+// contents of the store when fn succeeds. The store is locked meanwhile, so
+// fn is isolated from concurrent writes, which wait for it, and must only use
+// the store it is passed.
+func (s *MemoryStore) InTransaction(fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{memoryData: s.memoryData.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.memoryData = tx.memoryData
+	return nil
+}
+
+// AddOutboxEvent ...
+func (s *MemoryStore) AddOutboxEvent(event *OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event.ID = uint(len(s.outbox) + 1)
+	event.CreatedAt = time.Now().UTC()
+	s.outbox = append(s.outbox, *event)
+	return nil
+}
+
+// PendingOutboxEvents ...
+func (s *MemoryStore) PendingOutboxEvents(limit int) ([]OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []OutboxEvent
+	for _, event := range s.outbox {
+		if event.PublishedAt == nil && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// ReserveIdempotencyKey ...
+func (s *MemoryStore) ReserveIdempotencyKey(record *IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	var kept []IdempotencyRecord
+	for _, existing := range s.idempotency {
+		if existing.ExpiresAt.After(now) {
+			kept = append(kept, existing)
+		}
+	}
+	s.idempotency = kept
+
+	if i := s.idempotencyIndex(record.Tenant, record.Key); i >= 0 {
+		existing := &s.idempotency[i]
+		if existing.Fingerprint != record.Fingerprint || existing.Response != nil || existing.LockedUntil.After(now) {
+			return *existing, true, nil
+		}
+		existing.LockedUntil, existing.ExpiresAt = record.LockedUntil, record.ExpiresAt
+		return IdempotencyRecord{}, false, nil
+	}
+	s.idempotencyID++
+	record.ID = s.idempotencyID
+	record.CreatedAt = now
+	s.idempotency = append(s.idempotency, *record)
+	return IdempotencyRecord{}, false, nil
+}
+
+// CompleteIdempotencyKey ...
+func (s *MemoryStore) CompleteIdempotencyKey(tenant, key string, response []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.idempotencyIndex(tenant, key); i >= 0 {
+		s.idempotency[i].Response = response
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey ...
+func (s *MemoryStore) ReleaseIdempotencyKey(tenant, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.idempotencyIndex(tenant, key); i >= 0 {
+		s.idempotency = append(s.idempotency[:i], s.idempotency[i+1:]...)
+	}
+	return nil
+}
+
+func (s *MemoryStore) idempotencyIndex(tenant, key string) int {
+	for i, record := range s.idempotency {
+		if record.Tenant == tenant && record.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close ...
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// copyUser returns user without pointers shared with the stored copy
+func copyUser(user User) User {
+	if user.DisabledAt != nil {
+		disabledAt := *user.DisabledAt
+		user.DisabledAt = &disabledAt
+	}
+	if user.PasswordChangedAt != nil {
+		changedAt := *user.PasswordChangedAt
+		user.PasswordChangedAt = &changedAt
+	}
+	return user
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
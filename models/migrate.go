@@ -0,0 +1,342 @@
+package models
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationsDir is where migration files are kept in the source tree. They
+// are embedded in the binary when it is built.
+const MigrationsDir = "models/migrations"
+
+// migrationLockID identifies the advisory lock held while migrating, so
+// servers starting together do not apply the same migration twice
+const migrationLockID = 7306530455
+
+var (
+	migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)(?:\.([a-z0-9]+))?\.sql$`)
+	migrationName     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Migration is a versioned schema change, read from the files
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Where a database
+// engine needs different SQL, <version>_<name>.up.<dialect>.sql replaces the
+// up script on that gorm dialect, e.g. sqlite3, and likewise for down.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+
+	// variants holds the dialect specific scripts by "<up|down>.<dialect>"
+	variants map[string]string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Script returns the up or down script run on dialect
+func (m Migration) Script(dialect string, up bool) string {
+	direction, script := "down", m.Down
+	if up {
+		direction, script = "up", m.Up
+	}
+	if variant, ok := m.variants[direction+"."+dialect]; ok {
+		return variant
+	}
+	return script
+}
+
+// MigrationStatus tells whether a migration has been applied. Unknown is set
+// for migrations recorded in the database that this binary does not have.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Unknown   bool
+}
+
+// schemaMigration is a row of schema_migrations
+type schemaMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at %s NOT NULL
+)`
+
+// timestampType returns the column type of times on dialect. SQLite only
+// reads columns declared as datetime or timestamp back as times.
+func timestampType(dialect string) string {
+	if dialect == DriverSQLite {
+		return "datetime"
+	}
+	return "timestamp with time zone"
+}
+
+// Migrations returns the migrations embedded in the binary, oldest first
+func Migrations() ([]Migration, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return loadMigrations(dir)
+}
+
+func loadMigrations(dir fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.ParseUint(match[1], 10, 64)
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+
+		script, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case match[4] != "":
+			if m.variants == nil {
+				m.variants = map[string]string{}
+			}
+			m.variants[match[3]+"."+match[4]] = string(script)
+		case match[3] == "up":
+			m.Up = string(script)
+		default:
+			m.Down = string(script)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint64]schemaMigration, error) {
+	applied := map[uint64]schemaMigration{}
+	if !db.HasTable("schema_migrations") {
+		return applied, nil
+	}
+	var rows []schemaMigration
+	if err := db.Table("schema_migrations").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrationStatuses lists the migrations and whether they have been applied
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		row, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: ok, AppliedAt: row.AppliedAt})
+		delete(applied, m.Version)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: row.Version, Name: row.Name},
+			Applied:   true,
+			AppliedAt: row.AppliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// SchemaCurrent reports whether every migration has been applied
+func SchemaCurrent(db *gorm.DB) (bool, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return false, err
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MigrateUp applies up to steps pending migrations, all of them when steps
+// is 0, and returns the ones it applied
+func MigrateUp(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := db.Exec(fmt.Sprintf(createSchemaMigrations, timestampType(db.Dialect().GetName()))).Error; err != nil {
+		return nil, err
+	}
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if err := runMigration(db, status.Migration, true); err != nil {
+			return done, fmt.Errorf("migration %s: %v", status.Migration, err)
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts up to steps applied migrations, newest first, all of
+// them when steps is 0, and returns the ones it reverted
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if status.Unknown {
+			return done, fmt.Errorf("migration %s is not known to this binary and cannot be reverted", status.Migration)
+		}
+		if err := runMigration(db, status.Migration, false); err != nil {
+			return done, fmt.Errorf("migration %s: %v", status.Migration, err)
+		}
+		done = append(done, status.Migration)
+	}
+	return done, nil
+}
+
+// runMigration applies or reverts m and records it in one transaction
+func runMigration(db *gorm.DB, m Migration, up bool) error {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := runMigrationTx(tx, m, up); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func runMigrationTx(tx *gorm.DB, m Migration, up bool) error {
+	if tx.Dialect().GetName() == "postgres" {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Another process may have run the migration while we waited for the lock
+	var count int
+	if err := tx.Table("schema_migrations").Where("version = ?", m.Version).Count(&count).Error; err != nil {
+		return err
+	}
+	if up == (count > 0) {
+		return nil
+	}
+
+	// Run the script as is, without gorm expanding placeholders
+	if _, err := tx.CommonDB().Exec(m.Script(tx.Dialect().GetName(), up)); err != nil {
+		return err
+	}
+
+	if up {
+		return tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UTC()).Error
+	}
+	return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version).Error
+}
+
+// CreateMigration writes empty up and down files for a new migration in dir,
+// numbered after the newest migration there, and returns their paths
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.Trim(migrationName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var version uint64
+	for _, entry := range entries {
+		if match := migrationFileName.FindStringSubmatch(entry.Name()); match != nil {
+			if v, _ := strconv.ParseUint(match[1], 10, 64); v > version {
+				version = v
+			}
+		}
+	}
+	m := Migration{Version: version + 1, Name: name}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%s.%s.sql", m, direction))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return paths, err
+		}
+		fmt.Fprintf(file, "-- %s %s\n", m, direction)
+		if err := file.Close(); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	Describe("Migrations", func() {
+		It("returns the embedded migrations in order", func() {
+			migrations, err := models.Migrations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(migrations)).To(BeNumerically(">=", 2))
+			for i, m := range migrations {
+				Expect(m.Version).To(Equal(uint64(i + 1)))
+				Expect(m.Up).NotTo(BeEmpty())
+				Expect(m.Down).NotTo(BeEmpty())
+			}
+			Expect(migrations[0].String()).To(Equal("0001_create_users"))
+		})
+	})
+
+	Describe("MigrateUp and MigrateDown", func() {
+		It("apply and revert every migration on SQLite", func() {
+			db, err := models.DatabaseConfig{Driver: models.DriverSQLite, Name: ":memory:"}.Open()
+			Expect(err).NotTo(HaveOccurred())
+			defer db.Close()
+			migrations, err := models.Migrations()
+			Expect(err).NotTo(HaveOccurred())
+
+			done, err := models.MigrateUp(db, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(HaveLen(len(migrations)))
+			Expect(models.SchemaCurrent(db)).To(BeTrue())
+			statuses, err := models.MigrationStatuses(db)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statuses[0].AppliedAt).NotTo(BeZero())
+
+			done, err = models.MigrateDown(db, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(HaveLen(len(migrations)))
+			Expect(models.SchemaCurrent(db)).To(BeFalse())
+			Expect(db.HasTable("users")).To(BeFalse())
+
+			_, err = models.MigrateUp(db, 0)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("use dialect specific scripts where there are some", func() {
+			migrations, err := models.Migrations()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(migrations[0].Script(models.DriverPostgres, true)).To(Equal(migrations[0].Up))
+			Expect(migrations[0].Script(models.DriverSQLite, true)).To(ContainSubstring("AUTOINCREMENT"))
+			Expect(migrations[0].Script(models.DriverSQLite, false)).To(Equal(migrations[0].Down))
+		})
+	})
+
+	Describe("CreateMigration", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "migrations")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("numbers the migration after the newest one", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "0007_add_roles.up.sql"), nil, 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "0007_add_roles.down.sql"), nil, 0644)).To(Succeed())
+
+			paths, err := models.CreateMigration(dir, "Add Password History")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{
+				filepath.Join(dir, "0008_add_password_history.up.sql"),
+				filepath.Join(dir, "0008_add_password_history.down.sql"),
+			}))
+			for _, path := range paths {
+				Expect(path).To(BeAnExistingFile())
+			}
+		})
+
+		It("rejects names without letters or digits", func() {
+			_, err := models.CreateMigration(dir, "--")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
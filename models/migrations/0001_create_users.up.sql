@@ -0,0 +1,26 @@
+-- Users and their locations, as previously created by AutoMigrate. IF NOT
+-- EXISTS lets databases set up by AutoMigrate adopt the migrations.
+CREATE TABLE IF NOT EXISTS users (
+	id serial PRIMARY KEY,
+	created_at timestamp with time zone,
+	updated_at timestamp with time zone,
+	deleted_at timestamp with time zone,
+	first_name text,
+	last_name text,
+	email text UNIQUE,
+	password text,
+	age integer,
+	role text
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS locations (
+	id serial PRIMARY KEY,
+	created_at timestamp with time zone,
+	updated_at timestamp with time zone,
+	deleted_at timestamp with time zone,
+	city text,
+	country text,
+	user_id integer
+);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations (deleted_at);
@@ -0,0 +1,26 @@
+-- SQLite version of 0001_create_users.up.sql. Times are declared datetime so
+-- the driver reads them back as times.
+CREATE TABLE IF NOT EXISTS users (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	first_name text,
+	last_name text,
+	email text UNIQUE,
+	password text,
+	age integer,
+	role text
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS locations (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	city text,
+	country text,
+	user_id integer
+);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations (deleted_at);
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+	id serial PRIMARY KEY,
+	created_at timestamp with time zone,
+	tenant text,
+	seq bigint,
+	type text,
+	actor text,
+	subject text,
+	peer_ip text,
+	user_agent text,
+	outcome text,
+	reason text,
+	prev_hash text,
+	hash text
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject ON audit_events (subject);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_events_tenant_seq ON audit_events (tenant, seq);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+	id serial PRIMARY KEY,
+	created_at timestamp with time zone,
+	tenant text,
+	seq bigint,
+	hash text,
+	signature text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_checkpoints_tenant_seq ON audit_checkpoints (tenant, seq);
+
+-- Recorded events are never changed
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
@@ -0,0 +1,40 @@
+-- SQLite version of 0002_create_audit_events.up.sql
+CREATE TABLE IF NOT EXISTS audit_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	tenant text,
+	seq bigint,
+	type text,
+	actor text,
+	subject text,
+	peer_ip text,
+	user_agent text,
+	outcome text,
+	reason text,
+	prev_hash text,
+	hash text
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject ON audit_events (subject);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_events_tenant_seq ON audit_events (tenant, seq);
+
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	tenant text,
+	seq bigint,
+	hash text,
+	signature text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_checkpoints_tenant_seq ON audit_checkpoints (tenant, seq);
+
+-- Recorded events are never changed
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+	SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
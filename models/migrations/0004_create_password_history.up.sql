@@ -0,0 +1,8 @@
+-- Hashes of the passwords users had, to refuse reusing recent ones
+CREATE TABLE IF NOT EXISTS password_history (
+	id serial PRIMARY KEY,
+	user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	password text NOT NULL,
+	created_at timestamp with time zone NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id, id);
@@ -0,0 +1,8 @@
+-- SQLite version of 0004_create_password_history.up.sql
+CREATE TABLE IF NOT EXISTS password_history (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	user_id integer NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	password text NOT NULL,
+	created_at datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id, id);
@@ -0,0 +1,5 @@
+-- When users last set their password, and whether they must set a new one
+-- at their next login. Existing passwords count from the creation of the user.
+ALTER TABLE users ADD COLUMN password_changed_at timestamp with time zone;
+ALTER TABLE users ADD COLUMN must_change_password boolean NOT NULL DEFAULT false;
+UPDATE users SET password_changed_at = created_at;
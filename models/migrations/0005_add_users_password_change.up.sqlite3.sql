@@ -0,0 +1,4 @@
+-- SQLite version of 0005_add_users_password_change.up.sql
+ALTER TABLE users ADD COLUMN password_changed_at datetime;
+ALTER TABLE users ADD COLUMN must_change_password boolean NOT NULL DEFAULT false;
+UPDATE users SET password_changed_at = created_at;
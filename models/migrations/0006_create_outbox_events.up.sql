@@ -0,0 +1,11 @@
+-- Events written in the transaction of the change they announce, for a relay
+-- to publish once committed
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id serial PRIMARY KEY,
+	created_at timestamp with time zone NOT NULL,
+	topic text NOT NULL,
+	key text NOT NULL,
+	payload text NOT NULL,
+	published_at timestamp with time zone
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
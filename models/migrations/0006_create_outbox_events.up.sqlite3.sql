@@ -0,0 +1,10 @@
+-- SQLite version of 0006_create_outbox_events.up.sql
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime NOT NULL,
+	topic text NOT NULL,
+	key text NOT NULL,
+	payload text NOT NULL,
+	published_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events (id) WHERE published_at IS NULL;
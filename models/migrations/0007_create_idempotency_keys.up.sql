@@ -0,0 +1,13 @@
+-- Responses of requests made with an idempotency key, replayed for retries
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id serial PRIMARY KEY,
+	tenant text NOT NULL,
+	key text NOT NULL,
+	method text NOT NULL,
+	fingerprint text NOT NULL,
+	response bytea,
+	created_at timestamp with time zone NOT NULL,
+	expires_at timestamp with time zone NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_tenant_key ON idempotency_keys (tenant, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,13 @@
+-- SQLite version of 0007_create_idempotency_keys.up.sql
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	tenant text NOT NULL,
+	key text NOT NULL,
+	method text NOT NULL,
+	fingerprint text NOT NULL,
+	response blob,
+	created_at datetime NOT NULL,
+	expires_at datetime NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_tenant_key ON idempotency_keys (tenant, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
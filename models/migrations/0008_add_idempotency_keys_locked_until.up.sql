@@ -0,0 +1,4 @@
+-- Until when the request holding a key runs, after which a retry may take the
+-- key over. Reservations made before count as expired.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until timestamp with time zone;
+UPDATE idempotency_keys SET locked_until = created_at;
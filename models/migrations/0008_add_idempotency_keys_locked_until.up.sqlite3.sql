@@ -0,0 +1,3 @@
+-- SQLite version of 0008_add_idempotency_keys_locked_until.up.sql
+ALTER TABLE idempotency_keys ADD COLUMN locked_until datetime;
+UPDATE idempotency_keys SET locked_until = created_at;
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TopicUserCreated announces a signup, keyed by the email of the user
+const TopicUserCreated = "user.created"
+
+// OutboxEvent announces a change to other services. It is written in the
+// transaction of the change, so it exists exactly when the change was
+// committed, and published by a relay afterwards.
+type OutboxEvent struct {
+	ID        uint `gorm:"primary_key"`
+	CreatedAt time.Time
+	Topic     string
+	Key       string
+	// Payload is the JSON encoded event
+	Payload     string
+	PublishedAt *time.Time
+}
+
+// UserCreatedEvent is the payload of TopicUserCreated events
+type UserCreatedEvent struct {
+	ID        uint      `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// newOutboxEvent returns the event of topic and key with payload encoded as
+// JSON
+func newOutboxEvent(topic, key string, payload interface{}) (OutboxEvent, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	return OutboxEvent{Topic: topic, Key: key, Payload: string(encoded)}, nil
+}
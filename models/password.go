@@ -0,0 +1,222 @@
+package models
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Password hashes are stored as "<algorithm>$<encoded hash>". Values without
+// a known algorithm are passwords encrypted with Key before hashes were
+// introduced, and are read as "aes".
+const (
+	PasswordBcrypt       = "bcrypt"
+	PasswordPBKDF2SHA1   = "pbkdf2_sha1"
+	PasswordPBKDF2SHA256 = "pbkdf2_sha256"
+	PasswordPBKDF2SHA512 = "pbkdf2_sha512"
+	PasswordSHA1         = "sha1"
+	PasswordSHA256       = "sha256"
+	PasswordSHA512       = "sha512"
+	PasswordSSHA         = "ssha"
+	PasswordSSHA256      = "ssha256"
+	PasswordSSHA512      = "ssha512"
+	PasswordAES          = "aes"
+)
+
+// PasswordCost is the bcrypt cost of new password hashes. Hashes with a
+// lower cost are upgraded on login.
+var PasswordCost = bcrypt.DefaultCost
+
+// ErrPasswordHash is returned for stored hashes that cannot be read
+var ErrPasswordHash = errors.New("password hash is malformed")
+
+// passwordMatcher compares a password against a parsed hash
+type passwordMatcher func(password string) bool
+
+// passwordSchemes parse the encoded part of a hash for each algorithm
+var passwordSchemes = map[string]func(encoded string) (passwordMatcher, error){
+	PasswordBcrypt:       parseBcrypt,
+	PasswordPBKDF2SHA1:   parsePBKDF2(sha1.New),
+	PasswordPBKDF2SHA256: parsePBKDF2(sha256.New),
+	PasswordPBKDF2SHA512: parsePBKDF2(sha512.New),
+	PasswordSHA1:         parseSaltedSHA(sha1.New),
+	PasswordSHA256:       parseSaltedSHA(sha256.New),
+	PasswordSHA512:       parseSaltedSHA(sha512.New),
+	PasswordSSHA:         parseSSHA(sha1.New),
+	PasswordSSHA256:      parseSSHA(sha256.New),
+	PasswordSSHA512:      parseSSHA(sha512.New),
+	PasswordAES:          parseAES,
+}
+
+// ldapSchemes maps the RFC 2307 prefixes of salted SHA hashes exported from
+// LDAP directories to their algorithm
+var ldapSchemes = map[string]string{
+	"{SSHA}":    PasswordSSHA,
+	"{SSHA256}": PasswordSSHA256,
+	"{SSHA512}": PasswordSSHA512,
+}
+
+// HashPassword returns the hash stored for password
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return PasswordBcrypt + "$" + string(hashed), nil
+}
+
+// VerifyPassword reports whether password matches the stored hash, and
+// whether the hash should be replaced by HashPassword(password) because it
+// uses an older algorithm or a lower cost
+func VerifyPassword(stored, password string) (match bool, rehash bool, err error) {
+	algorithm, encoded := splitPasswordHash(stored)
+	matches, err := passwordSchemes[algorithm](encoded)
+	if err != nil {
+		return false, false, err
+	}
+	if !matches(password) {
+		return false, false, nil
+	}
+	if algorithm != PasswordBcrypt {
+		return true, true, nil
+	}
+	cost, _ := bcrypt.Cost([]byte(encoded))
+	return true, cost < PasswordCost, nil
+}
+
+// NormalizePasswordHash checks a hash imported from another system and
+// returns it tagged with its algorithm. Besides "<algorithm>$<encoded>" it
+// accepts bare bcrypt hashes ($2a$, $2b$, $2y$) and LDAP {SSHA} values.
+func NormalizePasswordHash(hash string) (string, error) {
+	hash = strings.TrimSpace(hash)
+	if strings.HasPrefix(hash, "$2") {
+		hash = PasswordBcrypt + "$" + hash
+	}
+	for prefix, algorithm := range ldapSchemes {
+		if len(hash) > len(prefix) && strings.EqualFold(hash[:len(prefix)], prefix) {
+			hash = algorithm + "$" + hash[len(prefix):]
+			break
+		}
+	}
+
+	algorithm, encoded := splitPasswordHash(hash)
+	if !strings.HasPrefix(hash, algorithm+"$") {
+		return "", errors.New("unknown password hash format")
+	}
+	if _, err := passwordSchemes[algorithm](encoded); err != nil {
+		return "", fmt.Errorf("%s password hash: %v", algorithm, err)
+	}
+	return hash, nil
+}
+
+// PasswordAlgorithm returns the algorithm of a stored hash
+func PasswordAlgorithm(stored string) string {
+	algorithm, _ := splitPasswordHash(stored)
+	return algorithm
+}
+
+func splitPasswordHash(stored string) (algorithm, encoded string) {
+	if i := strings.Index(stored, "$"); i > 0 {
+		if _, ok := passwordSchemes[stored[:i]]; ok {
+			return stored[:i], stored[i+1:]
+		}
+	}
+	return PasswordAES, stored
+}
+
+func parseBcrypt(encoded string) (passwordMatcher, error) {
+	if _, err := bcrypt.Cost([]byte(encoded)); err != nil {
+		return nil, ErrPasswordHash
+	}
+	return func(password string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+	}, nil
+}
+
+// parsePBKDF2 reads "<iterations>$<salt>$<base64 key>" as written by Django
+func parsePBKDF2(h func() hash.Hash) func(string) (passwordMatcher, error) {
+	return func(encoded string) (passwordMatcher, error) {
+		parts := strings.SplitN(encoded, "$", 3)
+		if len(parts) != 3 {
+			return nil, ErrPasswordHash
+		}
+		iterations, err := strconv.Atoi(parts[0])
+		if err != nil || iterations < 1 {
+			return nil, ErrPasswordHash
+		}
+		salt := []byte(parts[1])
+		key, err := decodeBase64(parts[2])
+		if err != nil || len(key) == 0 {
+			return nil, ErrPasswordHash
+		}
+		return func(password string) bool {
+			return subtle.ConstantTimeCompare(pbkdf2.Key([]byte(password), salt, iterations, len(key), h), key) == 1
+		}, nil
+	}
+}
+
+// parseSaltedSHA reads "<salt>$<hex digest>" where the digest is of the salt
+// followed by the password
+func parseSaltedSHA(h func() hash.Hash) func(string) (passwordMatcher, error) {
+	return func(encoded string) (passwordMatcher, error) {
+		parts := strings.SplitN(encoded, "$", 2)
+		if len(parts) != 2 {
+			return nil, ErrPasswordHash
+		}
+		salt := parts[0]
+		digest, err := hex.DecodeString(parts[1])
+		if err != nil || len(digest) != h().Size() {
+			return nil, ErrPasswordHash
+		}
+		return func(password string) bool {
+			d := h()
+			d.Write([]byte(salt + password))
+			return subtle.ConstantTimeCompare(d.Sum(nil), digest) == 1
+		}, nil
+	}
+}
+
+// parseSSHA reads the base64 of a digest of the password followed by the
+// salt, with the salt appended
+func parseSSHA(h func() hash.Hash) func(string) (passwordMatcher, error) {
+	return func(encoded string) (passwordMatcher, error) {
+		raw, err := decodeBase64(encoded)
+		size := h().Size()
+		if err != nil || len(raw) <= size {
+			return nil, ErrPasswordHash
+		}
+		digest, salt := raw[:size], raw[size:]
+		return func(password string) bool {
+			d := h()
+			d.Write([]byte(password))
+			d.Write(salt)
+			return subtle.ConstantTimeCompare(d.Sum(nil), digest) == 1
+		}, nil
+	}
+}
+
+func parseAES(encoded string) (passwordMatcher, error) {
+	plaintext, err := decrypt(encoded)
+	if err != nil {
+		return nil, ErrPasswordHash
+	}
+	return func(password string) bool {
+		return subtle.ConstantTimeCompare([]byte(plaintext), []byte(password)) == 1
+	}, nil
+}
+
+// decodeBase64 accepts standard base64 with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
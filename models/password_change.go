@@ -0,0 +1,151 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "perScoreAuth/perScoreProto/user"
+)
+
+// ErrPasswordChangeRequired is returned by CreateSession instead of a
+// session when the password must be changed first
+var ErrPasswordChangeRequired = errors.New("password change required")
+
+// ErrNoUsersSelected is returned by RequirePasswordChange for a filter that
+// selects every user without all
+var ErrNoUsersSelected = errors.New("select users by email, role or creation date, or all users")
+
+// ErrInvalidCredentials is returned by ChangePassword when the email and
+// password do not match an enabled user
+var ErrInvalidCredentials = errors.New("invalid email and password combination")
+
+// errFindingUser is returned by ChangePassword when the user could not be
+// read from the store
+var errFindingUser = errors.New("finding the user failed")
+
+// passwordChangeReason returns why user must change their password before
+// logging in, "" when they need not
+func passwordChangeReason(tenant string, user User, now time.Time) string {
+	if user.MustChangePassword {
+		return "password_change_required"
+	}
+	if PasswordPolicies.For(tenant, user.Role).Expired(user.PasswordChangedAt, now) {
+		return "password_expired"
+	}
+	return ""
+}
+
+// ChangePassword replaces the password of the user authenticating with
+// in.Email and in.Password. Users whose password expired or who are required
+// to change it use it instead of logging in.
+// Requests refused are returned as ErrInvalidCredentials, a
+// *PasswordPolicyError or ErrPasswordReused, other errors are failures of the
+// store.
+func (user User) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest, store Store) (*pb.ChangePasswordResponse, error) {
+	var response = new(pb.ChangePasswordResponse)
+	user, reason := authenticate(store, in.Email, in.Password)
+	if reason != "" {
+		response.Status = "FAILURE"
+		response.Message = "Invalid email and password combination!"
+		RecordAuditEvent(ctx, store, AuditPasswordChange, in.Email, AuditFailure, reason)
+		if reason == "database_error" {
+			return response, errFindingUser
+		}
+		return response, ErrInvalidCredentials
+	}
+
+	var err error = &PasswordPolicyError{Violations: []PasswordViolation{{Rule: "Required", Message: "is required"}}}
+	if in.NewPassword != "" {
+		err = checkNewPassword(ctx, store, user, in.NewPassword)
+	}
+	if err == nil {
+		err = storePassword(store, user.ID, in.NewPassword)
+	}
+	switch e := err.(type) {
+	case nil:
+		response.Status = "SUCCESS"
+		response.Message = "Your password has been changed."
+		RecordAuditEvent(ctx, store, AuditPasswordChange, in.Email, AuditSuccess, "")
+		return response, nil
+	case *PasswordPolicyError:
+		trans := translatorFrom(ctx)
+		for _, violation := range e.Violations {
+			response.Fields = append(response.Fields, &pb.ChangePasswordResponse_Field{
+				Name:       "new_password",
+				Validation: violation.Rule,
+				Message:    translateViolation(trans, violation),
+			})
+		}
+		reason = "validation_failed"
+	default:
+		reason = "database_error"
+		if err == ErrPasswordReused {
+			response.Fields = append(response.Fields, &pb.ChangePasswordResponse_Field{
+				Name:       "new_password",
+				Validation: "Reused",
+				Message:    translateViolation(translatorFrom(ctx), PasswordViolation{Rule: "Reused", Message: "was used recently, choose another one"}),
+			})
+			reason = "password_reused"
+		}
+	}
+	response.Status = "FAILURE"
+	response.Message = "Password change failed. Please try again."
+	RecordAuditEvent(ctx, store, AuditPasswordChange, in.Email, AuditFailure, reason)
+	return response, err
+}
+
+// checkNewPassword returns why password cannot replace the password of user:
+// a *PasswordPolicyError, ErrPasswordReused, or an error reading the history
+func checkNewPassword(ctx context.Context, store Store, user User, password string) error {
+	owner := PasswordOwner{Email: user.Email, FirstName: user.FirstName, LastName: user.LastName}
+	if err := CheckPassword(AuditContextFrom(ctx).Tenant, user.Role, password, owner); err != nil {
+		return err
+	}
+	return checkPasswordHistory(store, user, password)
+}
+
+// storePassword hashes password and stores it for the user with id, as
+// changed now
+func storePassword(store Store, id uint, password string) error {
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := store.ChangePassword(id, hashed, time.Now()); err != nil {
+		return err
+	}
+	recordPassword(store, id, hashed)
+	return nil
+}
+
+// RequirePasswordChange makes the users matching filter, disabled ones
+// included, change their password at their next login. It returns the users
+// that were not required to already. A filter selecting every user is only
+// accepted with all.
+func RequirePasswordChange(ctx context.Context, store Store, filter UserFilter, all bool) ([]User, error) {
+	if !filter.selects() && !all {
+		return nil, ErrNoUsersSelected
+	}
+	filter.IncludeDisabled = true
+	filter.Limit = 0
+	users, err := store.ListUsers(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var flagged []User
+	for _, user := range users {
+		if user.MustChangePassword {
+			continue
+		}
+		err := store.UpdateMustChangePassword(user.ID, true)
+		recordAdminAuditEvent(ctx, store, AuditPasswordChangeRequired, user.Email, err)
+		if err != nil {
+			return flagged, err
+		}
+		user.MustChangePassword = true
+		flagged = append(flagged, user)
+	}
+	return flagged, nil
+}
@@ -0,0 +1,193 @@
+package models_test
+
+import (
+	"context"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("Password change", func() {
+	var (
+		store    *models.MemoryStore
+		ctx      context.Context
+		user     models.User
+		policies *models.PasswordPolicySet
+	)
+
+	signup := func(email, role string) models.User {
+		_, err := user.CreateInDB(ctx, &pb.CreateUserRequest{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     email,
+			Password:  "s3cret-Passw0rd",
+			Age:       36,
+			Role:      role,
+			Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
+		}, store)
+		Expect(err).NotTo(HaveOccurred())
+		created, err := store.FindUserByEmail(email)
+		Expect(err).NotTo(HaveOccurred())
+		return created
+	}
+
+	login := func(password string) *pb.GetSessionResponse {
+		response, _ := user.CreateSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com", Password: password}, store)
+		return response
+	}
+
+	change := func(password, newPassword string) (*pb.ChangePasswordResponse, error) {
+		return user.ChangePassword(ctx, &pb.ChangePasswordRequest{Email: "ada@example.com", Password: password, NewPassword: newPassword}, store)
+	}
+
+	auditReasons := func(eventType string) []string {
+		events, err := store.ListAuditEvents(models.AuditEventFilter{Type: eventType})
+		Expect(err).NotTo(HaveOccurred())
+		var reasons []string
+		for _, event := range events {
+			reasons = append(reasons, event.Outcome+":"+event.Reason)
+		}
+		return reasons
+	}
+
+	BeforeEach(func() {
+		store = models.NewMemoryStore()
+		ctx = context.Background()
+		models.PasswordCost = bcrypt.MinCost
+		policies = models.PasswordPolicies
+		models.PasswordPolicies = models.NewPasswordPolicySet(models.DefaultPasswordPolicy)
+	})
+
+	AfterEach(func() {
+		models.PasswordCost = bcrypt.DefaultCost
+		models.PasswordPolicies = policies
+	})
+
+	Describe("PasswordPolicy.Expired", func() {
+		It("expires passwords older than the maximum age", func() {
+			now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+			changedAt := now.AddDate(0, 0, -90)
+			policy := models.PasswordPolicy{MaxAgeDays: 90}
+			Expect(policy.Expired(&changedAt, now)).To(BeTrue())
+			Expect(policy.Expired(&changedAt, now.Add(-time.Second))).To(BeFalse())
+			Expect(policy.Expired(nil, now)).To(BeFalse())
+			Expect(models.PasswordPolicy{}.Expired(&changedAt, now)).To(BeFalse())
+		})
+	})
+
+	Describe("CreateSession", func() {
+		It("requires a change of expired passwords instead of starting a session", func() {
+			ada := signup("ada@example.com", "admin")
+			Expect(ada.PasswordChangedAt).NotTo(BeNil())
+			models.PasswordPolicies.Set("", "admin", models.PasswordPolicy{MaxAgeDays: 90})
+			Expect(login("s3cret-Passw0rd").Status).To(Equal("SUCCESS"))
+
+			Expect(store.ChangePassword(ada.ID, ada.Password, time.Now().AddDate(0, 0, -91))).To(Succeed())
+			response, err := user.CreateSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com", Password: "s3cret-Passw0rd"}, store)
+			Expect(err).To(Equal(models.ErrPasswordChangeRequired))
+			Expect(response.Status).To(Equal("PASSWORD_CHANGE_REQUIRED"))
+			Expect(response.PasswordChangeRequired).To(BeTrue())
+			Expect(response.Token).To(BeEmpty())
+
+			// A wrong password does not reveal that the password expired
+			Expect(login("wrong").PasswordChangeRequired).To(BeFalse())
+			Expect(auditReasons(models.AuditLoginFailure)).To(Equal([]string{"failure:wrong_password"}))
+			Expect(auditReasons(models.AuditLoginBlocked)).To(Equal([]string{"failure:password_expired"}))
+		})
+
+		It("requires a change when an administrator asked for one", func() {
+			signup("ada@example.com", "admin")
+			flagged, err := models.RequirePasswordChange(ctx, store, models.UserFilter{Emails: []string{"ada@example.com"}}, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flagged).To(HaveLen(1))
+
+			Expect(login("s3cret-Passw0rd").Status).To(Equal("PASSWORD_CHANGE_REQUIRED"))
+			Expect(auditReasons(models.AuditLoginBlocked)).To(Equal([]string{"failure:password_change_required"}))
+			Expect(auditReasons(models.AuditLoginFailure)).To(BeEmpty())
+		})
+	})
+
+	Describe("ChangePassword", func() {
+		BeforeEach(func() {
+			signup("ada@example.com", "admin")
+			_, err := models.RequirePasswordChange(ctx, store, models.UserFilter{Role: "admin"}, false)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("replaces the password and lifts the requirement", func() {
+			response, err := change("s3cret-Passw0rd", "an0ther-Passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Status).To(Equal("SUCCESS"))
+
+			Expect(login("s3cret-Passw0rd").Status).To(Equal("FAILURE"))
+			Expect(login("an0ther-Passw0rd").Status).To(Equal("SUCCESS"))
+			ada, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ada.MustChangePassword).To(BeFalse())
+			Expect(time.Since(*ada.PasswordChangedAt)).To(BeNumerically("<", time.Minute))
+			Expect(auditReasons(models.AuditPasswordChange)).To(Equal([]string{"success:"}))
+		})
+
+		It("reports why the new password is refused", func() {
+			response, err := change("wrong", "an0ther-Passw0rd")
+			Expect(err).To(HaveOccurred())
+			Expect(response.Fields).To(BeEmpty())
+
+			fields := func(newPassword string) []string {
+				response, err := change("s3cret-Passw0rd", newPassword)
+				Expect(err).To(HaveOccurred())
+				Expect(response.Status).To(Equal("FAILURE"))
+				var names []string
+				for _, field := range response.Fields {
+					Expect(field.Message).NotTo(BeEmpty())
+					names = append(names, field.Name+":"+field.Validation)
+				}
+				return names
+			}
+			Expect(fields("")).To(Equal([]string{"new_password:Required"}))
+			Expect(fields("short")).To(Equal([]string{"new_password:MinLength"}))
+			Expect(fields("s3cret-Passw0rd")).To(Equal([]string{"new_password:Reused"}))
+
+			// The current password is refused without a history too
+			size := models.PasswordHistorySize
+			defer func() { models.PasswordHistorySize = size }()
+			models.PasswordHistorySize = 0
+			Expect(fields("s3cret-Passw0rd")).To(Equal([]string{"new_password:Reused"}))
+
+			Expect(login("s3cret-Passw0rd").Status).To(Equal("PASSWORD_CHANGE_REQUIRED"))
+			Expect(auditReasons(models.AuditPasswordChange)).To(Equal([]string{
+				"failure:password_reused", "failure:password_reused", "failure:validation_failed", "failure:validation_failed", "failure:wrong_password",
+			}))
+		})
+	})
+
+	Describe("RequirePasswordChange", func() {
+		It("flags the users matching every criterion once", func() {
+			signup("ada@example.com", "admin")
+			signup("bob@example.com", "responder")
+			signup("carl@example.com", "responder")
+
+			_, err := models.RequirePasswordChange(ctx, store, models.UserFilter{}, false)
+			Expect(err).To(Equal(models.ErrNoUsersSelected))
+
+			flagged, err := models.RequirePasswordChange(ctx, store, models.UserFilter{Role: "responder", Emails: []string{"ada@example.com", "bob@example.com"}}, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flagged).To(HaveLen(1))
+			Expect(flagged[0].Email).To(Equal("bob@example.com"))
+
+			flagged, err = models.RequirePasswordChange(ctx, store, models.UserFilter{CreatedBefore: time.Now().Add(-time.Hour)}, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flagged).To(BeEmpty())
+
+			flagged, err = models.RequirePasswordChange(ctx, store, models.UserFilter{}, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(flagged).To(HaveLen(2))
+			Expect(auditReasons(models.AuditPasswordChangeRequired)).To(HaveLen(3))
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PasswordHistorySize is the number of recent passwords of a user that a new
+// password may not repeat, the current one included. History is neither
+// written nor checked when it is zero.
+var PasswordHistorySize = 5
+
+// ErrPasswordReused is returned when a new password is one of the user's
+// recent passwords
+var ErrPasswordReused = errors.New("password was used recently, choose another one")
+
+// PasswordHistory is the hash of a password a user had
+type PasswordHistory struct {
+	ID        uint `gorm:"primary_key"`
+	UserID    uint
+	Password  string
+	CreatedAt time.Time
+}
+
+// TableName keeps gorm from pluralizing the table name
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
+
+// checkPasswordHistory returns ErrPasswordReused when password matches the
+// current password of user, even without a history, or one of the
+// PasswordHistorySize newest entries of its history
+func checkPasswordHistory(history PasswordHistoryStore, user User, password string) error {
+	hashes := []string{user.Password}
+	if PasswordHistorySize > 0 {
+		entries, err := history.PasswordHistory(user.ID, PasswordHistorySize)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			hashes = append(hashes, entry.Password)
+		}
+	}
+	for _, hash := range hashes {
+		// Hashes that cannot be read cannot be compared, they do not block
+		// the change
+		if match, _, err := VerifyPassword(hash, password); err == nil && match {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// recordPassword adds the password hash just set for the user with id to its
+// history, pruning entries beyond PasswordHistorySize. The password is set
+// already, so failures are logged rather than returned.
+func recordPassword(history PasswordHistoryStore, id uint, hash string) {
+	if PasswordHistorySize <= 0 {
+		return
+	}
+	if err := history.AddPasswordHistory(id, hash, PasswordHistorySize); err != nil {
+		log.WithFields(log.Fields{"user_id": id, "error": err}).Error("Recording password history failed")
+	}
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy describes the passwords users may choose. Lengths count
+// characters; passwords are refused beyond MaxPasswordBytes whatever the
+// policy.
+type PasswordPolicy struct {
+	MinLength int `mapstructure:"min_length"`
+	// MaxLength is unlimited when zero
+	MaxLength     int  `mapstructure:"max_length"`
+	RequireUpper  bool `mapstructure:"require_upper"`
+	RequireLower  bool `mapstructure:"require_lower"`
+	RequireDigit  bool `mapstructure:"require_digit"`
+	RequireSymbol bool `mapstructure:"require_symbol"`
+	// RejectPersonal rejects passwords containing the user's email, the
+	// part of it before the @, or their first or last name
+	RejectPersonal bool `mapstructure:"reject_personal"`
+	// RejectCommon rejects passwords listed in CommonPasswords
+	RejectCommon bool `mapstructure:"reject_common"`
+	// RejectBreached rejects passwords found in BreachedPasswords more than
+	// MaxBreachCount times
+	RejectBreached bool `mapstructure:"reject_breached"`
+	MaxBreachCount int  `mapstructure:"max_breach_count"`
+	// MaxAgeDays is how long a password may be used before it must be
+	// changed, unlimited when zero
+	MaxAgeDays int `mapstructure:"max_age_days"`
+}
+
+// MaxPasswordBytes is the length of the longest password bcrypt hashes whole:
+// it ignores the bytes beyond, so a longer password would be accepted with any
+// ending. Non-Latin characters take up to 4 bytes.
+const MaxPasswordBytes = 72
+
+// DefaultPasswordPolicy applies to tenants and roles without a policy of
+// their own
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MaxLength:      64,
+	RejectPersonal: true,
+	RejectCommon:   true,
+	RejectBreached: true,
+}
+
+// PasswordPolicies returns the policy of a tenant and role
+var PasswordPolicies = NewPasswordPolicySet(DefaultPasswordPolicy)
+
+// CommonPasswords is the denylist checked by policies rejecting common
+// passwords, see LoadPasswordDenylist
+var CommonPasswords PasswordDenylist
+
+// PasswordPolicySet holds a default policy and overrides for tenants, roles
+// or roles within a tenant
+type PasswordPolicySet struct {
+	Default   PasswordPolicy
+	overrides map[passwordPolicyScope]PasswordPolicy
+}
+
+type passwordPolicyScope struct {
+	tenant, role string
+}
+
+// NewPasswordPolicySet returns a set applying policy everywhere
+func NewPasswordPolicySet(policy PasswordPolicy) *PasswordPolicySet {
+	return &PasswordPolicySet{Default: policy, overrides: map[passwordPolicyScope]PasswordPolicy{}}
+}
+
+// Set makes policy apply to role in tenant. An empty tenant or role matches
+// any.
+func (s *PasswordPolicySet) Set(tenant, role string, policy PasswordPolicy) {
+	s.overrides[passwordPolicyScope{tenant, role}] = policy
+}
+
+// For returns the most specific policy of role in tenant: the one set for
+// both, then for the tenant, then for the role, then the default
+func (s *PasswordPolicySet) For(tenant, role string) PasswordPolicy {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	for _, scope := range []passwordPolicyScope{{tenant, role}, {tenant, ""}, {"", role}} {
+		if policy, ok := s.overrides[scope]; ok {
+			return policy
+		}
+	}
+	return s.Default
+}
+
+// PasswordViolation is a rule of a policy a password breaks
+type PasswordViolation struct {
+	// Rule names the rule, as reported in CreateUserResponse fields
+	Rule    string
+	Message string
+	// Param is the limit a length rule sets, used to translate Message
+	Param string
+}
+
+// PasswordPolicyError lists the rules a password breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return "password " + strings.Join(messages, ", ")
+}
+
+// PasswordOwner is what a policy rejecting personal information compares a
+// password with
+type PasswordOwner struct {
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// Check returns the rules of the policy password breaks, nil when it
+// complies
+func (p PasswordPolicy) Check(password string, owner PasswordOwner) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(rule, message string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: message})
+	}
+	addLength := func(rule, format string, limit int) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, limit), Param: strconv.Itoa(limit)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		addLength("MinLength", "must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		addLength("MaxLength", "must be at most %d characters long", p.MaxLength)
+	} else if len(password) > MaxPasswordBytes {
+		addLength("MaxBytes", "must be at most %d bytes long, fewer characters when they are not Latin letters", MaxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add("Uppercase", "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add("Lowercase", "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add("Digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add("Symbol", "must contain a symbol")
+	}
+
+	if p.RejectPersonal && containsPersonalInfo(password, owner) {
+		add("PersonalInfo", "must not contain your email or name")
+	}
+	if p.RejectCommon && CommonPasswords.Contains(password) {
+		add("Common", "is too common, choose a less predictable one")
+	}
+	if p.RejectBreached && BreachedPasswords.Count(password) > p.MaxBreachCount {
+		add("Breached", "has appeared in a data breach, choose another one")
+	}
+	return violations
+}
+
+// Expired reports whether a password set at changedAt must be changed at
+// now. Passwords set at an unknown time do not expire.
+func (p PasswordPolicy) Expired(changedAt *time.Time, now time.Time) bool {
+	if p.MaxAgeDays <= 0 || changedAt == nil {
+		return false
+	}
+	return !now.Before(changedAt.AddDate(0, 0, p.MaxAgeDays))
+}
+
+// personalInfoMinLength is the length below which names and emails are too
+// short to be looked for in passwords
+const personalInfoMinLength = 3
+
+func containsPersonalInfo(password string, owner PasswordOwner) bool {
+	password = strings.ToLower(password)
+	email := strings.ToLower(owner.Email)
+	candidates := []string{email, owner.FirstName, owner.LastName}
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		candidates = append(candidates, email[:at])
+	}
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		if utf8.RuneCountInString(candidate) >= personalInfoMinLength && strings.Contains(password, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPassword checks password against the policy of role in tenant,
+// returning a *PasswordPolicyError when it breaks any rule
+func CheckPassword(tenant, role, password string, owner PasswordOwner) error {
+	violations := PasswordPolicies.For(tenant, role).Check(password, owner)
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// PasswordDenylist is a set of passwords compared case-insensitively
+type PasswordDenylist map[string]struct{}
+
+// Contains reports whether password is listed
+func (d PasswordDenylist) Contains(password string) bool {
+	_, ok := d[strings.ToLower(password)]
+	return ok
+}
+
+// LoadPasswordDenylist reads the file at path, one password per line.
+// Empty lines and lines starting with # are skipped.
+func LoadPasswordDenylist(path string) (PasswordDenylist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	denylist := PasswordDenylist{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+	return denylist, nil
+}
+
+// errPasswordPolicy is returned by newUser when only the password policy
+// failed, its violations being in the field responses
+var errPasswordPolicy = errors.New("password does not comply with the password policy")
@@ -0,0 +1,111 @@
+package models_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PasswordPolicy", func() {
+	owner := models.PasswordOwner{Email: "ada.l@example.com", FirstName: "Ada", LastName: "Lovelace"}
+
+	rules := func(violations []models.PasswordViolation) []string {
+		var names []string
+		for _, violation := range violations {
+			names = append(names, violation.Rule)
+		}
+		return names
+	}
+
+	It("checks lengths in characters", func() {
+		policy := models.PasswordPolicy{MinLength: 4, MaxLength: 6}
+		Expect(rules(policy.Check("abc", owner))).To(Equal([]string{"MinLength"}))
+		Expect(policy.Check("äöüß", owner)).To(BeEmpty())
+		Expect(rules(policy.Check("abcdefg", owner))).To(Equal([]string{"MaxLength"}))
+	})
+
+	It("refuses passwords bcrypt would truncate", func() {
+		policy := models.PasswordPolicy{MaxLength: 64}
+		Expect(policy.Check(strings.Repeat("a", 64), owner)).To(BeEmpty())
+		// 30 characters of 3 bytes each
+		Expect(rules(policy.Check(strings.Repeat("€", 30), owner))).To(Equal([]string{"MaxBytes"}))
+		Expect(rules(policy.Check(strings.Repeat("€", 65), owner))).To(Equal([]string{"MaxLength"}))
+		Expect(rules(models.PasswordPolicy{}.Check(strings.Repeat("a", 73), owner))).To(Equal([]string{"MaxBytes"}))
+	})
+
+	It("requires character classes", func() {
+		policy := models.PasswordPolicy{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+		Expect(rules(policy.Check("", owner))).To(Equal([]string{"Uppercase", "Lowercase", "Digit", "Symbol"}))
+		Expect(rules(policy.Check("abcDEF", owner))).To(Equal([]string{"Digit", "Symbol"}))
+		Expect(policy.Check("aB3 ", owner)).To(BeEmpty())
+		Expect(policy.Check("aB3€", owner)).To(BeEmpty())
+	})
+
+	It("rejects the email and names of the owner", func() {
+		policy := models.PasswordPolicy{RejectPersonal: true}
+		for _, password := range []string{"my-ADA.L-pass", "lovelace1815", "xx-Ada-xx", "ada.l@example.com!"} {
+			Expect(rules(policy.Check(password, owner))).To(Equal([]string{"PersonalInfo"}), password)
+		}
+		Expect(policy.Check("correct horse", models.PasswordOwner{Email: "al@example.com", FirstName: "Al"})).To(BeEmpty())
+	})
+
+	It("rejects denylisted passwords", func() {
+		denylist := models.CommonPasswords
+		defer func() { models.CommonPasswords = denylist }()
+		models.CommonPasswords = models.PasswordDenylist{"password1": {}}
+
+		policy := models.PasswordPolicy{RejectCommon: true}
+		Expect(rules(policy.Check("PassWord1", owner))).To(Equal([]string{"Common"}))
+		Expect(policy.Check("password2", owner)).To(BeEmpty())
+		Expect(models.PasswordPolicy{}.Check("password1", owner)).To(BeEmpty())
+	})
+
+	Describe("PasswordPolicySet", func() {
+		It("picks the most specific policy", func() {
+			set := models.NewPasswordPolicySet(models.PasswordPolicy{MinLength: 1})
+			set.Set("", "admin", models.PasswordPolicy{MinLength: 2})
+			set.Set("acme", "", models.PasswordPolicy{MinLength: 3})
+			set.Set("acme", "admin", models.PasswordPolicy{MinLength: 4})
+			set.Set(models.DefaultTenant, "responder", models.PasswordPolicy{MinLength: 5})
+
+			Expect(set.For("other", "responder").MinLength).To(Equal(1))
+			Expect(set.For("other", "admin").MinLength).To(Equal(2))
+			Expect(set.For("acme", "responder").MinLength).To(Equal(3))
+			Expect(set.For("acme", "admin").MinLength).To(Equal(4))
+			Expect(set.For("", "responder").MinLength).To(Equal(5))
+		})
+	})
+
+	Describe("CheckPassword", func() {
+		It("returns the violations as an error", func() {
+			err := models.CheckPassword("", "admin", "Ada", owner)
+			Expect(err).To(MatchError("password must be at least 8 characters long, must not contain your email or name"))
+			Expect(models.CheckPassword("", "admin", "s3cret-Passw0rd", owner)).To(Succeed())
+		})
+	})
+
+	Describe("LoadPasswordDenylist", func() {
+		It("reads one password per line, skipping comments", func() {
+			dir, err := ioutil.TempDir("", "denylist")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "common.txt")
+			Expect(ioutil.WriteFile(path, []byte("# top passwords\n123456\n\n  Qwerty \n"), 0600)).To(Succeed())
+
+			denylist, err := models.LoadPasswordDenylist(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(denylist).To(HaveLen(2))
+			Expect(denylist.Contains("QWERTY")).To(BeTrue())
+			Expect(denylist.Contains("# top passwords")).To(BeFalse())
+
+			_, err = models.LoadPasswordDenylist(filepath.Join(dir, "missing.txt"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
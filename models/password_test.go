@@ -0,0 +1,109 @@
+package models_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var _ = Describe("Password", func() {
+	cost := models.PasswordCost
+	BeforeEach(func() {
+		models.PasswordCost = bcrypt.MinCost + 1
+	})
+	AfterEach(func() {
+		models.PasswordCost = cost
+	})
+
+	legacyBcrypt := func(password string, cost int) string {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+		Expect(err).NotTo(HaveOccurred())
+		return string(hashed)
+	}
+
+	Describe("HashPassword", func() {
+		It("tags bcrypt hashes that need no upgrade", func() {
+			hashed, err := models.HashPassword("secret")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hashed).To(HavePrefix("bcrypt$$2a$"))
+			Expect(models.PasswordAlgorithm(hashed)).To(Equal(models.PasswordBcrypt))
+
+			match, rehash, err := models.VerifyPassword(hashed, "secret")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(match).To(BeTrue())
+			Expect(rehash).To(BeFalse())
+
+			match, _, err = models.VerifyPassword(hashed, "Secret")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(match).To(BeFalse())
+		})
+	})
+
+	Describe("VerifyPassword", func() {
+		pbkdf2Key := base64.StdEncoding.EncodeToString(pbkdf2.Key([]byte("secret"), []byte("seasalt"), 1000, 32, sha256.New))
+		saltedSHA := sha1.Sum([]byte("pepper" + "secret"))
+		ssha := sha1.Sum([]byte("secret" + "NaCl"))
+
+		legacy := map[string]func() string{
+			"untagged AES":             func() string { return models.Encrypt("secret") },
+			"bcrypt with a lower cost": func() string { return "bcrypt$" + legacyBcrypt("secret", bcrypt.MinCost) },
+			"PBKDF2":                   func() string { return "pbkdf2_sha256$1000$seasalt$" + pbkdf2Key },
+			"salted SHA":               func() string { return "sha1$pepper$" + hex.EncodeToString(saltedSHA[:]) },
+			"LDAP SSHA": func() string {
+				return "ssha$" + base64.StdEncoding.EncodeToString(append(ssha[:], "NaCl"...))
+			},
+		}
+		for name, stored := range legacy {
+			stored := stored
+			It("verifies and upgrades "+name+" hashes", func() {
+				match, rehash, err := models.VerifyPassword(stored(), "secret")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(match).To(BeTrue())
+				Expect(rehash).To(BeTrue())
+
+				match, _, err = models.VerifyPassword(stored(), "wrong")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(match).To(BeFalse())
+			})
+		}
+
+		It("fails for hashes it cannot read", func() {
+			_, _, err := models.VerifyPassword("sha1$pepper$nothex", "secret")
+			Expect(err).To(Equal(models.ErrPasswordHash))
+			_, _, err = models.VerifyPassword("garbage", "secret")
+			Expect(err).To(Equal(models.ErrPasswordHash))
+		})
+	})
+
+	Describe("NormalizePasswordHash", func() {
+		It("tags bare bcrypt and LDAP hashes", func() {
+			bare := legacyBcrypt("secret", bcrypt.MinCost)
+			Expect(models.NormalizePasswordHash(bare)).To(Equal("bcrypt$" + bare))
+
+			ssha := sha1.Sum([]byte("secret" + "NaCl"))
+			encoded := base64.StdEncoding.EncodeToString(append(ssha[:], "NaCl"...))
+			Expect(models.NormalizePasswordHash("{SSHA}" + encoded)).To(Equal("ssha$" + encoded))
+		})
+
+		It("keeps tagged hashes", func() {
+			Expect(models.NormalizePasswordHash(" pbkdf2_sha1$10$salt$c2VjcmV0 ")).To(Equal("pbkdf2_sha1$10$salt$c2VjcmV0"))
+		})
+
+		It("rejects unknown and malformed hashes", func() {
+			_, err := models.NormalizePasswordHash("md5$abc")
+			Expect(err).To(MatchError("unknown password hash format"))
+			_, err = models.NormalizePasswordHash(models.Encrypt("secret"))
+			Expect(err).To(MatchError("unknown password hash format"))
+			_, err = models.NormalizePasswordHash("pbkdf2_sha256$many$salt$c2VjcmV0")
+			Expect(err).To(MatchError(ContainSubstring("pbkdf2_sha256")))
+		})
+	})
+})
@@ -0,0 +1,324 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SessionStore is what starting a session needs from the user accounts
+type SessionStore interface {
+	// FindUserByEmail returns the user with email and its location, or
+	// ErrUserNotFound
+	FindUserByEmail(email string) (User, error)
+	// UpdatePassword replaces the stored password hash of the user with id
+	UpdatePassword(id uint, password string) error
+	// ChangePassword stores a password hash the user with id chose, or an
+	// administrator set, at changedAt, and clears MustChangePassword
+	ChangePassword(id uint, password string, changedAt time.Time) error
+}
+
+// PasswordHistoryStore keeps the hashes of the passwords users had
+type PasswordHistoryStore interface {
+	// AddPasswordHistory records hash as set for the user with id, keeping
+	// only its keep newest entries
+	AddPasswordHistory(id uint, hash string, keep int) error
+	// PasswordHistory returns the limit newest entries of the user with id,
+	// newest first
+	PasswordHistory(id uint, limit int) ([]PasswordHistory, error)
+}
+
+// UserStore keeps user accounts
+type UserStore interface {
+	SessionStore
+	PasswordHistoryStore
+	// CreateUser inserts user and its location, setting their IDs. Violated
+	// constraints are returned as a *ConstraintError, ErrEmailTaken for a
+	// taken email.
+	CreateUser(user *User) error
+	// ListUsers returns the users matching filter, oldest first
+	ListUsers(filter UserFilter) ([]User, error)
+	UpdateRole(id uint, role string) error
+	UpdateDisabledAt(id uint, disabledAt time.Time) error
+	UpdateMustChangePassword(id uint, must bool) error
+}
+
+// AuditStore keeps the audit log
+type AuditStore interface {
+	// AppendAuditEvent links event to the head of its tenant chain and
+	// stores it
+	AppendAuditEvent(event *AuditEvent) error
+	// ListAuditEvents returns the newest events matching filter
+	ListAuditEvents(filter AuditEventFilter) ([]AuditEvent, error)
+}
+
+// OutboxStore keeps the events to publish to other services
+type OutboxStore interface {
+	// AddOutboxEvent stores event, setting its ID and CreatedAt
+	AddOutboxEvent(event *OutboxEvent) error
+	// PendingOutboxEvents returns the limit oldest unpublished events
+	PendingOutboxEvents(limit int) ([]OutboxEvent, error)
+}
+
+// IdempotencyStore keeps the responses of requests made with an idempotency
+// key
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey stores record, without a response, unless an
+	// unexpired record with its tenant and key exists, which is returned with
+	// found set instead. A record of the same request whose lease ended
+	// without a response is taken over, with the lease and expiry of record.
+	// Expired records are deleted.
+	ReserveIdempotencyKey(record *IdempotencyRecord) (existing IdempotencyRecord, found bool, err error)
+	// CompleteIdempotencyKey stores the response of the request that reserved
+	// key in tenant
+	CompleteIdempotencyKey(tenant, key string, response []byte) error
+	// ReleaseIdempotencyKey deletes the record of key in tenant, so a retry
+	// runs the request again
+	ReleaseIdempotencyKey(tenant, key string) error
+}
+
+// Store is everything the service persists
+type Store interface {
+	UserStore
+	AuditStore
+	OutboxStore
+	IdempotencyStore
+	// InTransaction calls fn with a store whose writes are committed together
+	// when fn returns nil and rolled back otherwise. Calling it within fn
+	// joins the transaction. fn must only use the store it is passed, the
+	// store InTransaction was called on may wait for the transaction.
+	InTransaction(fn func(tx Store) error) error
+	Close() error
+}
+
+// TransactionError is a step of a unit of work that failed, all of its writes
+// being rolled back
+type TransactionError struct {
+	// Step names what was written, such as user, audit_event or commit
+	Step string
+	Err  error
+}
+
+func (e *TransactionError) Error() string {
+	return "writing " + e.Step + " failed: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the step
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// GormStore keeps users, audit and outbox events and idempotency records in a
+// SQL database through gorm
+type GormStore struct {
+	DB *gorm.DB
+	// inTx is set on the stores InTransaction passes to its function
+	inTx bool
+}
+
+// NewGormStore returns a store using db
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{DB: db}
+}
+
+// CreateUser ...
+func (s *GormStore) CreateUser(user *User) error {
+	return ConstraintViolation(s.DB.Create(user).Error)
+}
+
+// FindUserByEmail ...
+func (s *GormStore) FindUserByEmail(email string) (User, error) {
+	var user User
+	query := s.DB.Preload("Location").Where("email = ?", email).First(&user)
+	if query.RecordNotFound() {
+		return user, ErrUserNotFound
+	}
+	return user, query.Error
+}
+
+// ListUsers ...
+func (s *GormStore) ListUsers(filter UserFilter) ([]User, error) {
+	query := s.DB.Preload("Location").Order("id")
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if len(filter.Emails) > 0 {
+		query = query.Where("email IN (?)", filter.Emails)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore.UTC())
+	}
+	if !filter.IncludeDisabled {
+		query = query.Where("disabled_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var users []User
+	err := query.Find(&users).Error
+	return users, err
+}
+
+// UpdatePassword ...
+func (s *GormStore) UpdatePassword(id uint, password string) error {
+	return s.updateUser(id, map[string]interface{}{"password": password})
+}
+
+// ChangePassword ...
+func (s *GormStore) ChangePassword(id uint, password string, changedAt time.Time) error {
+	return s.updateUser(id, map[string]interface{}{
+		"password":             password,
+		"password_changed_at":  changedAt.UTC(),
+		"must_change_password": false,
+	})
+}
+
+// UpdateRole ...
+func (s *GormStore) UpdateRole(id uint, role string) error {
+	return s.updateUser(id, map[string]interface{}{"role": role})
+}
+
+// UpdateDisabledAt ...
+func (s *GormStore) UpdateDisabledAt(id uint, disabledAt time.Time) error {
+	return s.updateUser(id, map[string]interface{}{"disabled_at": disabledAt})
+}
+
+// UpdateMustChangePassword ...
+func (s *GormStore) UpdateMustChangePassword(id uint, must bool) error {
+	return s.updateUser(id, map[string]interface{}{"must_change_password": must})
+}
+
+func (s *GormStore) updateUser(id uint, values map[string]interface{}) error {
+	query := s.DB.Model(&User{}).Where("id = ?", id).Updates(values)
+	if query.Error == nil && query.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return query.Error
+}
+
+// AddPasswordHistory ...
+func (s *GormStore) AddPasswordHistory(id uint, hash string, keep int) error {
+	entry := PasswordHistory{UserID: id, Password: hash}
+	if err := s.DB.Create(&entry).Error; err != nil {
+		return err
+	}
+	return s.DB.Exec(`DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+		SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?)`, id, id, keep).Error
+}
+
+// PasswordHistory ...
+func (s *GormStore) PasswordHistory(id uint, limit int) ([]PasswordHistory, error) {
+	var entries []PasswordHistory
+	err := s.DB.Where("user_id = ?", id).Order("id desc").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// InTransaction ...
+func (s *GormStore) InTransaction(fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	db := s.DB.Begin()
+	if db.Error != nil {
+		return db.Error
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			db.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&GormStore{DB: db, inTx: true}); err != nil {
+		db.Rollback()
+		return err
+	}
+	return db.Commit().Error
+}
+
+// AppendAuditEvent ...
+func (s *GormStore) AppendAuditEvent(event *AuditEvent) error {
+	if s.inTx {
+		return appendAuditEventInTx(s.DB, event)
+	}
+	return appendAuditEvent(s.DB, event)
+}
+
+// ListAuditEvents ...
+func (s *GormStore) ListAuditEvents(filter AuditEventFilter) ([]AuditEvent, error) {
+	query := s.DB.Order("created_at desc, id desc")
+	if filter.Tenant != "" {
+		query = query.Where("tenant = ?", filter.Tenant)
+	}
+	if filter.Subject != "" {
+		query = query.Where("subject = ?", filter.Subject)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	// Times are compared in UTC, the zone events are stored in, since
+	// SQLite compares them as text
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+
+	var events []AuditEvent
+	err := query.Limit(filter.limit()).Find(&events).Error
+	return events, err
+}
+
+// AddOutboxEvent ...
+func (s *GormStore) AddOutboxEvent(event *OutboxEvent) error {
+	return s.DB.Create(event).Error
+}
+
+// PendingOutboxEvents ...
+func (s *GormStore) PendingOutboxEvents(limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := s.DB.Where("published_at IS NULL").Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// ReserveIdempotencyKey ...
+func (s *GormStore) ReserveIdempotencyKey(record *IdempotencyRecord) (IdempotencyRecord, bool, error) {
+	var existing IdempotencyRecord
+	now := time.Now().UTC()
+	if err := s.DB.Where("expires_at <= ?", now).Delete(IdempotencyRecord{}).Error; err != nil {
+		return existing, false, err
+	}
+	err := s.DB.Create(record).Error
+	if !isUniqueViolation(err) {
+		return existing, false, err
+	}
+
+	// The update succeeds for one of the retries taking over an abandoned
+	// reservation at the same time
+	query := s.DB.Model(IdempotencyRecord{}).
+		Where("tenant = ? AND key = ? AND fingerprint = ? AND response IS NULL AND locked_until <= ?", record.Tenant, record.Key, record.Fingerprint, now).
+		Updates(map[string]interface{}{"locked_until": record.LockedUntil, "expires_at": record.ExpiresAt})
+	if query.Error != nil || query.RowsAffected == 1 {
+		return existing, false, query.Error
+	}
+	err = s.DB.Where("tenant = ? AND key = ?", record.Tenant, record.Key).First(&existing).Error
+	return existing, err == nil, err
+}
+
+// CompleteIdempotencyKey ...
+func (s *GormStore) CompleteIdempotencyKey(tenant, key string, response []byte) error {
+	return s.DB.Model(IdempotencyRecord{}).Where("tenant = ? AND key = ?", tenant, key).Update("response", response).Error
+}
+
+// ReleaseIdempotencyKey ...
+func (s *GormStore) ReleaseIdempotencyKey(tenant, key string) error {
+	return s.DB.Where("tenant = ? AND key = ?", tenant, key).Delete(IdempotencyRecord{}).Error
+}
+
+// Close closes the database
+func (s *GormStore) Close() error {
+	return s.DB.Close()
+}
@@ -0,0 +1,382 @@
+package models_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// openSQLiteStore returns a store on a new in-memory SQLite database with all
+// migrations applied
+func openSQLiteStore() models.Store {
+	db, err := models.DatabaseConfig{Driver: models.DriverSQLite, Name: ":memory:"}.Open()
+	Expect(err).NotTo(HaveOccurred())
+	_, err = models.MigrateUp(db, 0)
+	Expect(err).NotTo(HaveOccurred())
+	return models.NewGormStore(db)
+}
+
+// openTestStore returns a store on the database of the test environment,
+// which must be migrated already
+func openTestStore() models.Store {
+	db, err := models.OpenDatabase("test")
+	Expect(err).NotTo(HaveOccurred())
+	return models.NewGormStore(db)
+}
+
+var _ = Describe("Store", func() {
+	stores := []struct {
+		name string
+		open func() models.Store
+	}{
+		{"MemoryStore", func() models.Store { return models.NewMemoryStore() }},
+		{"GormStore on SQLite", openSQLiteStore},
+	}
+	// Postgres needs a server, set TEST_DB_DRIVER and the other TEST_*
+	// variables to run against one
+	if os.Getenv("TEST_DB_DRIVER") != "" {
+		stores = append(stores, struct {
+			name string
+			open func() models.Store
+		}{"GormStore on " + os.Getenv("TEST_DB_DRIVER"), openTestStore})
+	}
+
+	for _, s := range stores {
+		open := s.open
+		Describe(s.name, func() {
+			var (
+				store models.Store
+				// run keeps the rows of this run apart from those left in a
+				// shared database by earlier runs
+				run string
+			)
+
+			BeforeEach(func() {
+				store = open()
+				run = fmt.Sprint(time.Now().UnixNano())
+			})
+
+			AfterEach(func() {
+				Expect(store.Close()).To(Succeed())
+			})
+
+			newUser := func(name, role string) *models.User {
+				return &models.User{
+					Email:    name + "-" + run + "@example.com",
+					Role:     role + "-" + run,
+					Password: "bcrypt$hash",
+					Location: models.Location{City: "London", Country: "GB"},
+				}
+			}
+
+			It("creates users concurrently", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer GinkgoRecover()
+						defer wg.Done()
+						Expect(store.CreateUser(newUser(fmt.Sprint("user", i), "responder"))).To(Succeed())
+					}(i)
+				}
+				wg.Wait()
+
+				users, err := store.ListUsers(models.UserFilter{Role: "responder-" + run})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(20))
+				for i, user := range users {
+					if i > 0 {
+						Expect(user.ID).To(BeNumerically(">", users[i-1].ID))
+					}
+					Expect(user.CreatedAt).NotTo(BeZero())
+					Expect(user.Location.UserID).To(Equal(user.ID))
+					Expect(user.Location.City).To(Equal("London"))
+				}
+			})
+
+			It("rejects taken emails", func() {
+				Expect(store.CreateUser(newUser("ada", "admin"))).To(Succeed())
+				Expect(store.CreateUser(newUser("ada", "admin"))).To(Equal(models.ErrEmailTaken))
+			})
+
+			It("finds, filters and updates users", func() {
+				ada, bob, carl := newUser("ada", "admin"), newUser("bob", "responder"), newUser("carl", "responder")
+				for _, user := range []*models.User{ada, bob, carl} {
+					Expect(store.CreateUser(user)).To(Succeed())
+				}
+				disabledAt := time.Now().UTC().Truncate(time.Second)
+				Expect(store.UpdateDisabledAt(bob.ID, disabledAt)).To(Succeed())
+				Expect(store.UpdateRole(ada.ID, "responder-"+run)).To(Succeed())
+				Expect(store.UpdatePassword(carl.ID, "bcrypt$other")).To(Succeed())
+				Expect(store.UpdateRole(1<<31, "admin")).To(Equal(models.ErrUserNotFound))
+
+				users, err := store.ListUsers(models.UserFilter{Role: "responder-" + run})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(2))
+				Expect(users[0].Email).To(Equal(ada.Email))
+				Expect(users[1].Password).To(Equal("bcrypt$other"))
+
+				users, err = store.ListUsers(models.UserFilter{Role: "responder-" + run, IncludeDisabled: true, Limit: 2})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(2))
+				Expect(users[1].Email).To(Equal(bob.Email))
+				Expect(users[1].DisabledAt).NotTo(BeNil())
+				Expect(users[1].DisabledAt.Equal(disabledAt)).To(BeTrue())
+
+				found, err := store.FindUserByEmail(carl.Email)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.ID).To(Equal(carl.ID))
+				Expect(found.Location.Country).To(Equal("GB"))
+				_, err = store.FindUserByEmail("dan-" + run + "@example.com")
+				Expect(err).To(Equal(models.ErrUserNotFound))
+			})
+
+			It("changes passwords and requires changes", func() {
+				ada, bob := newUser("ada", "admin"), newUser("bob", "admin")
+				for _, user := range []*models.User{ada, bob} {
+					Expect(store.CreateUser(user)).To(Succeed())
+				}
+				Expect(store.UpdateMustChangePassword(ada.ID, true)).To(Succeed())
+				Expect(store.UpdateMustChangePassword(bob.ID, true)).To(Succeed())
+				changedAt := time.Now().UTC().Truncate(time.Second)
+				Expect(store.ChangePassword(ada.ID, "bcrypt$new", changedAt)).To(Succeed())
+				Expect(store.ChangePassword(1<<31, "bcrypt$new", changedAt)).To(Equal(models.ErrUserNotFound))
+
+				found, err := store.FindUserByEmail(ada.Email)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.Password).To(Equal("bcrypt$new"))
+				Expect(found.MustChangePassword).To(BeFalse())
+				Expect(found.PasswordChangedAt).NotTo(BeNil())
+				Expect(found.PasswordChangedAt.Equal(changedAt)).To(BeTrue())
+				found, err = store.FindUserByEmail(bob.Email)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found.MustChangePassword).To(BeTrue())
+
+				users, err := store.ListUsers(models.UserFilter{Emails: []string{bob.Email, "nobody@example.com"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(1))
+				Expect(users[0].ID).To(Equal(bob.ID))
+				users, err = store.ListUsers(models.UserFilter{Role: "admin-" + run, CreatedBefore: time.Now().Add(time.Minute)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(HaveLen(2))
+				users, err = store.ListUsers(models.UserFilter{Role: "admin-" + run, CreatedBefore: time.Now().Add(-time.Minute)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(users).To(BeEmpty())
+			})
+
+			It("keeps the newest password hashes of every user", func() {
+				ada, bob := newUser("ada", "admin"), newUser("bob", "admin")
+				for _, user := range []*models.User{ada, bob} {
+					Expect(store.CreateUser(user)).To(Succeed())
+				}
+				for i := 1; i <= 4; i++ {
+					Expect(store.AddPasswordHistory(ada.ID, fmt.Sprint("bcrypt$ada", i), 3)).To(Succeed())
+				}
+				Expect(store.AddPasswordHistory(bob.ID, "bcrypt$bob", 3)).To(Succeed())
+
+				entries, err := store.PasswordHistory(ada.ID, 10)
+				Expect(err).NotTo(HaveOccurred())
+				var hashes []string
+				for _, entry := range entries {
+					Expect(entry.UserID).To(Equal(ada.ID))
+					Expect(entry.CreatedAt).NotTo(BeZero())
+					hashes = append(hashes, entry.Password)
+				}
+				Expect(hashes).To(Equal([]string{"bcrypt$ada4", "bcrypt$ada3", "bcrypt$ada2"}))
+
+				entries, err = store.PasswordHistory(ada.ID, 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+				entries, err = store.PasswordHistory(bob.ID, 10)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+			})
+
+			It("commits units of work or rolls all their writes back", func() {
+				ada, bob := newUser("ada", "admin"), newUser("bob", "admin")
+				pending := func() []string {
+					events, err := store.PendingOutboxEvents(1000)
+					Expect(err).NotTo(HaveOccurred())
+					var keys []string
+					for _, event := range events {
+						if event.Key == ada.Email || event.Key == bob.Email {
+							keys = append(keys, event.Key)
+						}
+					}
+					return keys
+				}
+
+				failure := errors.New("failed")
+				err := store.InTransaction(func(tx models.Store) error {
+					Expect(tx.CreateUser(ada)).To(Succeed())
+					Expect(tx.AddPasswordHistory(ada.ID, ada.Password, 3)).To(Succeed())
+					Expect(tx.AppendAuditEvent(&models.AuditEvent{Tenant: run, Type: models.AuditSignup})).To(Succeed())
+					Expect(tx.AddOutboxEvent(&models.OutboxEvent{Topic: models.TopicUserCreated, Key: ada.Email, Payload: "{}"})).To(Succeed())
+					return tx.InTransaction(func(models.Store) error { return failure })
+				})
+				Expect(err).To(Equal(failure))
+				_, err = store.FindUserByEmail(ada.Email)
+				Expect(err).To(Equal(models.ErrUserNotFound))
+				events, err := store.ListAuditEvents(models.AuditEventFilter{Tenant: run})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(BeEmpty())
+				Expect(pending()).To(BeEmpty())
+
+				Expect(store.InTransaction(func(tx models.Store) error {
+					if err := tx.CreateUser(bob); err != nil {
+						return err
+					}
+					return tx.AddOutboxEvent(&models.OutboxEvent{Topic: models.TopicUserCreated, Key: bob.Email, Payload: "{}"})
+				})).To(Succeed())
+				_, err = store.FindUserByEmail(bob.Email)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pending()).To(Equal([]string{bob.Email}))
+			})
+
+			It("keeps concurrent writes when a unit of work rolls back", func() {
+				ada, bob := newUser("ada", "admin"), newUser("bob", "admin")
+				done := make(chan error)
+				failure := errors.New("failed")
+				err := store.InTransaction(func(tx models.Store) error {
+					Expect(tx.CreateUser(ada)).To(Succeed())
+					go func() { done <- store.CreateUser(bob) }()
+					return failure
+				})
+				Expect(err).To(Equal(failure))
+				Expect(<-done).To(Succeed())
+
+				_, err = store.FindUserByEmail(ada.Email)
+				Expect(err).To(Equal(models.ErrUserNotFound))
+				_, err = store.FindUserByEmail(bob.Email)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("chains audit events appended concurrently within and outside units of work", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer GinkgoRecover()
+						defer wg.Done()
+						event := &models.AuditEvent{Tenant: run, Type: models.AuditSignup}
+						if i%2 == 0 {
+							Expect(store.AppendAuditEvent(event)).To(Succeed())
+							return
+						}
+						Expect(store.InTransaction(func(tx models.Store) error {
+							return tx.AppendAuditEvent(event)
+						})).To(Succeed())
+					}(i)
+				}
+				wg.Wait()
+
+				events, err := store.ListAuditEvents(models.AuditEventFilter{Tenant: run})
+				Expect(err).NotTo(HaveOccurred())
+				seqs := map[uint64]bool{}
+				for _, event := range events {
+					seqs[event.Seq] = true
+				}
+				Expect(seqs).To(HaveLen(10))
+				Expect(seqs).To(HaveKey(uint64(10)))
+			})
+
+			It("reserves idempotency keys per tenant until they expire", func() {
+				key := "key-" + run
+				reserve := func(tenant, fingerprint string, expiresAt time.Time) (models.IdempotencyRecord, bool) {
+					record := &models.IdempotencyRecord{Tenant: tenant, Key: key, Method: "/user.User/CreateUser", Fingerprint: fingerprint, ExpiresAt: expiresAt}
+					existing, found, err := store.ReserveIdempotencyKey(record)
+					Expect(err).NotTo(HaveOccurred())
+					return existing, found
+				}
+				later := time.Now().Add(time.Hour)
+
+				_, found := reserve("a", "first", later)
+				Expect(found).To(BeFalse())
+				existing, found := reserve("a", "second", later)
+				Expect(found).To(BeTrue())
+				Expect(existing.Fingerprint).To(Equal("first"))
+				Expect(existing.Response).To(BeNil())
+				_, found = reserve("b", "second", later)
+				Expect(found).To(BeFalse())
+
+				Expect(store.CompleteIdempotencyKey("a", key, []byte("response"))).To(Succeed())
+				existing, found = reserve("a", "first", later)
+				Expect(found).To(BeTrue())
+				Expect(existing.Response).To(Equal([]byte("response")))
+
+				Expect(store.ReleaseIdempotencyKey("a", key)).To(Succeed())
+				_, found = reserve("a", "third", time.Now().Add(-time.Second))
+				Expect(found).To(BeFalse())
+				_, found = reserve("a", "fourth", later)
+				Expect(found).To(BeFalse(), "expired keys are reserved again")
+			})
+
+			It("lets a retry take over a key whose lease ended without a response", func() {
+				key := "key-" + run
+				reserve := func(fingerprint string, lockedUntil time.Time) (models.IdempotencyRecord, bool) {
+					record := &models.IdempotencyRecord{Tenant: run, Key: key, Method: "/user.User/CreateUser", Fingerprint: fingerprint, LockedUntil: lockedUntil, ExpiresAt: time.Now().Add(time.Hour)}
+					existing, found, err := store.ReserveIdempotencyKey(record)
+					Expect(err).NotTo(HaveOccurred())
+					return existing, found
+				}
+
+				_, found := reserve("first", time.Now().Add(-time.Second))
+				Expect(found).To(BeFalse())
+				_, found = reserve("other", time.Now().Add(time.Minute))
+				Expect(found).To(BeTrue(), "only the same request takes a key over")
+				_, found = reserve("first", time.Now().Add(time.Minute))
+				Expect(found).To(BeFalse())
+				existing, found := reserve("first", time.Now().Add(time.Minute))
+				Expect(found).To(BeTrue(), "the lease was taken over")
+				Expect(existing.Response).To(BeNil())
+				Expect(existing.LockedUntil).To(BeTemporally(">", time.Now()))
+
+				Expect(store.CompleteIdempotencyKey(run, key, []byte("response"))).To(Succeed())
+				existing, found = reserve("first", time.Now().Add(time.Minute))
+				Expect(found).To(BeTrue(), "completed keys are replayed")
+				Expect(existing.Response).To(Equal([]byte("response")))
+			})
+
+			It("chains audit events per tenant and lists the newest first", func() {
+				a, b := "a-"+run, "b-"+run
+				for _, tenant := range []string{a, b, a} {
+					Expect(store.AppendAuditEvent(&models.AuditEvent{Tenant: tenant, Type: models.AuditSignup, Subject: run})).To(Succeed())
+				}
+
+				events, err := store.ListAuditEvents(models.AuditEventFilter{Tenant: a})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(HaveLen(2))
+				Expect(events[0].Seq).To(BeEquivalentTo(2))
+				Expect(events[0].PrevHash).To(Equal(events[1].Hash))
+				Expect(events[0].Hash).To(Equal(events[0].ComputeHash()))
+
+				events, err = store.ListAuditEvents(models.AuditEventFilter{Subject: run, Limit: 1})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Tenant).To(Equal(a))
+				Expect(events[0].Seq).To(BeEquivalentTo(2))
+
+				events, err = store.ListAuditEvents(models.AuditEventFilter{Subject: run, From: time.Now().Add(time.Minute)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(events).To(BeEmpty())
+			})
+		})
+	}
+
+	It("keeps audit events append-only on SQLite", func() {
+		store := openSQLiteStore()
+		defer store.Close()
+		Expect(store.AppendAuditEvent(&models.AuditEvent{Tenant: "a", Type: models.AuditSignup})).To(Succeed())
+
+		db := store.(*models.GormStore).DB
+		Expect(db.Exec("UPDATE audit_events SET reason = 'edited'").Error).To(MatchError(ContainSubstring("append-only")))
+		Expect(db.Delete(&models.AuditEvent{}).Error).To(MatchError(ContainSubstring("append-only")))
+	})
+})
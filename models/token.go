@@ -0,0 +1,134 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Token is the content of a session token: the plaintext
+// "email,role,ttl_minutes,issued_at,key_id" encrypted with Key. Consumers
+// that split on commas still find the email, role and TTL first.
+type Token struct {
+	Email string
+	Role  string
+	TTL   time.Duration
+	// IssuedAt and KeyID are zero for tokens issued before they were added
+	IssuedAt time.Time
+	KeyID    string
+}
+
+// Token verification errors
+var (
+	ErrTokenMalformed = errors.New("token is malformed or encrypted with another key")
+	ErrTokenLegacy    = errors.New("token has no issue time, its expiry is unknown")
+	ErrTokenKey       = errors.New("token was issued with another key")
+	ErrTokenExpired   = errors.New("token has expired")
+)
+
+// KeyID identifies Key without revealing it
+func KeyID() string {
+	sum := sha256.Sum256([]byte(Key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// IssueToken returns a session token for email and role valid for ttl,
+// rounded up to whole minutes
+func IssueToken(email, role string, ttl time.Duration, now time.Time) string {
+	minutes := int64((ttl + time.Minute - 1) / time.Minute)
+	return Encrypt(fmt.Sprintf("%s,%s,%d,%d,%s", email, role, minutes, now.Unix(), KeyID()))
+}
+
+// ExpiresAt returns when the token expires, zero when unknown
+func (t Token) ExpiresAt() time.Time {
+	if t.IssuedAt.IsZero() {
+		return time.Time{}
+	}
+	return t.IssuedAt.Add(t.TTL)
+}
+
+// ParseToken decrypts token and returns its content without checking
+// whether it is still valid
+func ParseToken(token string) (Token, error) {
+	plaintext, err := decrypt(strings.TrimSpace(token))
+	if err != nil || !utf8.ValidString(plaintext) {
+		return Token{}, ErrTokenMalformed
+	}
+
+	fields := strings.Split(plaintext, ",")
+	var t Token
+	var minutes string
+	switch {
+	case len(fields) >= 5:
+		n := len(fields)
+		issuedAt, err := strconv.ParseInt(fields[n-2], 10, 64)
+		if err != nil {
+			return Token{}, ErrTokenMalformed
+		}
+		t = Token{
+			Email:    strings.Join(fields[:n-4], ","),
+			Role:     fields[n-4],
+			IssuedAt: time.Unix(issuedAt, 0).UTC(),
+			KeyID:    fields[n-1],
+		}
+		minutes = fields[n-3]
+	case len(fields) == 3:
+		t = Token{Email: fields[0], Role: fields[1]}
+		minutes = fields[2]
+	default:
+		return Token{}, ErrTokenMalformed
+	}
+
+	ttl, err := strconv.ParseInt(minutes, 10, 64)
+	if err != nil || ttl < 0 {
+		return Token{}, ErrTokenMalformed
+	}
+	t.TTL = time.Duration(ttl) * time.Minute
+	return t, nil
+}
+
+// VerifyToken parses token and checks that it was issued with the current
+// key and has not expired at now
+func VerifyToken(token string, now time.Time) (Token, error) {
+	t, err := ParseToken(token)
+	switch {
+	case err != nil:
+		return t, err
+	case t.IssuedAt.IsZero():
+		return t, ErrTokenLegacy
+	case t.KeyID != KeyID():
+		return t, ErrTokenKey
+	case !now.Before(t.ExpiresAt()):
+		return t, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// decrypt reverses Encrypt, returning an error for input Encrypt cannot
+// have produced
+func decrypt(cryptoText string) (string, error) {
+	ciphertext, err := base64.URLEncoding.DecodeString(cryptoText)
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) < aes.BlockSize {
+		return "", errors.New("ciphertext too short")
+	}
+
+	block, err := aes.NewCipher([]byte(Key))
+	if err != nil {
+		return "", err
+	}
+	iv := ciphertext[:aes.BlockSize]
+	plaintext := ciphertext[aes.BlockSize:]
+	cipher.NewCFBDecrypter(block, iv).XORKeyStream(plaintext, plaintext)
+	return string(plaintext), nil
+}
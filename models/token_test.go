@@ -0,0 +1,63 @@
+package models_test
+
+import (
+	"time"
+
+	"perScoreAuth/models"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token", func() {
+	issuedAt := time.Date(2017, 10, 21, 4, 39, 52, 0, time.UTC)
+
+	It("carries the claims, issue time and key ID", func() {
+		token, err := models.ParseToken(models.IssueToken("ada@example.com", "admin", models.SessionDuration, issuedAt))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal(models.Token{
+			Email:    "ada@example.com",
+			Role:     "admin",
+			TTL:      models.SessionDuration,
+			IssuedAt: issuedAt,
+			KeyID:    models.KeyID(),
+		}))
+		Expect(token.ExpiresAt()).To(Equal(issuedAt.Add(models.SessionDuration)))
+	})
+
+	It("rounds the TTL up to whole minutes", func() {
+		token, err := models.ParseToken(models.IssueToken("ada@example.com", "admin", 90*time.Second, issuedAt))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.TTL).To(Equal(2 * time.Minute))
+	})
+
+	It("verifies the expiry", func() {
+		raw := models.IssueToken("ada@example.com", "admin", models.SessionDuration, issuedAt)
+		_, err := models.VerifyToken(raw, issuedAt.Add(time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = models.VerifyToken(raw, issuedAt.Add(models.SessionDuration))
+		Expect(err).To(Equal(models.ErrTokenExpired))
+	})
+
+	It("reads tokens issued before the issue time was added", func() {
+		token, err := models.ParseToken(models.Encrypt("ada@example.com,responder,10"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal(models.Token{Email: "ada@example.com", Role: "responder", TTL: 10 * time.Minute}))
+		Expect(token.ExpiresAt().IsZero()).To(BeTrue())
+
+		_, err = models.VerifyToken(models.Encrypt("ada@example.com,responder,10"), issuedAt)
+		Expect(err).To(Equal(models.ErrTokenLegacy))
+	})
+
+	It("rejects tokens issued with another key", func() {
+		_, err := models.VerifyToken(models.Encrypt("ada@example.com,admin,10,1508560792,00000000"), issuedAt)
+		Expect(err).To(Equal(models.ErrTokenKey))
+	})
+
+	It("rejects malformed tokens", func() {
+		for _, raw := range []string{"", "not base64!", "c2hvcnQ=", models.Encrypt("ada@example.com")} {
+			_, err := models.ParseToken(raw)
+			Expect(err).To(Equal(models.ErrTokenMalformed), raw)
+		}
+	})
+})
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/chuckpreslar/inflect"
+	"github.com/go-playground/locales/de"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/pinzolo/casee"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// messageCatalog holds the messages of every supported locale. Keys are
+// validation tags, with a _length suffix for the length of strings, taken
+// and too_long for constraint violations, "field." and the snake cased name
+// of a field, and "password." and the rule of a PasswordViolation.
+// Parameters are {0} for the field name and {1} for the validation
+// parameter, or {0} for the parameter of a password rule.
+var messageCatalog = map[string]map[string]string{
+	"en": {
+		"field.first_name":      "First name",
+		"field.last_name":       "Last name",
+		"field.email":           "Email",
+		"field.password":        "Password",
+		"field.age":             "Age",
+		"field.role":            "Role",
+		"field.city":            "City",
+		"field.country":         "Country",
+		"required":              "{0} is required",
+		"email":                 "{0} must be a valid email address",
+		"min":                   "{0} must be at least {1}",
+		"max":                   "{0} must be at most {1}",
+		"min_length":            "{0} must be at least {1} characters long",
+		"max_length":            "{0} must be at most {1} characters long",
+		"country":               "{0} must be an ISO 3166-1 alpha-2 country code",
+		"invalid":               "{0} is invalid",
+		"taken":                 "{0} is already taken",
+		"too_long":              "{0} is too long",
+		"password.Required":     "Password is required",
+		"password.MinLength":    "Password must be at least {0} characters long",
+		"password.MaxLength":    "Password must be at most {0} characters long",
+		"password.MaxBytes":     "Password must be at most {0} bytes long, fewer characters when they are not Latin letters",
+		"password.Uppercase":    "Password must contain an uppercase letter",
+		"password.Lowercase":    "Password must contain a lowercase letter",
+		"password.Digit":        "Password must contain a digit",
+		"password.Symbol":       "Password must contain a symbol",
+		"password.PersonalInfo": "Password must not contain your email or name",
+		"password.Common":       "Password is too common, choose a less predictable one",
+		"password.Breached":     "Password has appeared in a data breach, choose another one",
+		"password.Reused":       "Password was used recently, choose another one",
+	},
+	"fr": {
+		"field.first_name":      "prénom",
+		"field.last_name":       "nom",
+		"field.email":           "adresse e-mail",
+		"field.password":        "mot de passe",
+		"field.age":             "âge",
+		"field.role":            "rôle",
+		"field.city":            "ville",
+		"field.country":         "pays",
+		"required":              "Le champ {0} est obligatoire",
+		"email":                 "Le champ {0} doit être une adresse e-mail valide",
+		"min":                   "Le champ {0} doit valoir au moins {1}",
+		"max":                   "Le champ {0} doit valoir au plus {1}",
+		"min_length":            "Le champ {0} doit contenir au moins {1} caractères",
+		"max_length":            "Le champ {0} doit contenir au plus {1} caractères",
+		"country":               "Le champ {0} doit être un code pays ISO 3166-1 alpha-2",
+		"invalid":               "Le champ {0} n'est pas valide",
+		"taken":                 "Le champ {0} est déjà utilisé",
+		"too_long":              "Le champ {0} est trop long",
+		"password.Required":     "Le mot de passe est obligatoire",
+		"password.MinLength":    "Le mot de passe doit contenir au moins {0} caractères",
+		"password.MaxLength":    "Le mot de passe doit contenir au plus {0} caractères",
+		"password.MaxBytes":     "Le mot de passe doit faire au plus {0} octets, moins de caractères s'ils ne sont pas des lettres latines",
+		"password.Uppercase":    "Le mot de passe doit contenir une lettre majuscule",
+		"password.Lowercase":    "Le mot de passe doit contenir une lettre minuscule",
+		"password.Digit":        "Le mot de passe doit contenir un chiffre",
+		"password.Symbol":       "Le mot de passe doit contenir un symbole",
+		"password.PersonalInfo": "Le mot de passe ne doit contenir ni votre adresse e-mail ni votre nom",
+		"password.Common":       "Ce mot de passe est trop courant, choisissez-en un moins prévisible",
+		"password.Breached":     "Ce mot de passe est apparu dans une fuite de données, choisissez-en un autre",
+		"password.Reused":       "Ce mot de passe a été utilisé récemment, choisissez-en un autre",
+	},
+	"es": {
+		"field.first_name":      "nombre",
+		"field.last_name":       "apellido",
+		"field.email":           "correo electrónico",
+		"field.password":        "contraseña",
+		"field.age":             "edad",
+		"field.role":            "rol",
+		"field.city":            "ciudad",
+		"field.country":         "país",
+		"required":              "El campo {0} es obligatorio",
+		"email":                 "El campo {0} debe ser una dirección de correo electrónico válida",
+		"min":                   "El campo {0} debe ser como mínimo {1}",
+		"max":                   "El campo {0} debe ser como máximo {1}",
+		"min_length":            "El campo {0} debe tener al menos {1} caracteres",
+		"max_length":            "El campo {0} debe tener como máximo {1} caracteres",
+		"country":               "El campo {0} debe ser un código de país ISO 3166-1 alfa-2",
+		"invalid":               "El campo {0} no es válido",
+		"taken":                 "El campo {0} ya está en uso",
+		"too_long":              "El campo {0} es demasiado largo",
+		"password.Required":     "La contraseña es obligatoria",
+		"password.MinLength":    "La contraseña debe tener al menos {0} caracteres",
+		"password.MaxLength":    "La contraseña debe tener como máximo {0} caracteres",
+		"password.MaxBytes":     "La contraseña debe tener como máximo {0} bytes, menos caracteres si no son letras latinas",
+		"password.Uppercase":    "La contraseña debe contener una letra mayúscula",
+		"password.Lowercase":    "La contraseña debe contener una letra minúscula",
+		"password.Digit":        "La contraseña debe contener un dígito",
+		"password.Symbol":       "La contraseña debe contener un símbolo",
+		"password.PersonalInfo": "La contraseña no debe contener tu correo electrónico ni tu nombre",
+		"password.Common":       "La contraseña es demasiado común, elige una menos predecible",
+		"password.Breached":     "La contraseña ha aparecido en una filtración de datos, elige otra",
+		"password.Reused":       "La contraseña se usó recientemente, elige otra",
+	},
+	"de": {
+		"field.first_name":      "Vorname",
+		"field.last_name":       "Nachname",
+		"field.email":           "E-Mail-Adresse",
+		"field.password":        "Passwort",
+		"field.age":             "Alter",
+		"field.role":            "Rolle",
+		"field.city":            "Stadt",
+		"field.country":         "Land",
+		"required":              "{0} ist ein Pflichtfeld",
+		"email":                 "{0} muss eine gültige E-Mail-Adresse sein",
+		"min":                   "{0} muss mindestens {1} sein",
+		"max":                   "{0} darf höchstens {1} sein",
+		"min_length":            "{0} muss mindestens {1} Zeichen lang sein",
+		"max_length":            "{0} darf höchstens {1} Zeichen lang sein",
+		"country":               "{0} muss ein ISO-3166-1-Alpha-2-Ländercode sein",
+		"invalid":               "{0} ist ungültig",
+		"taken":                 "{0} wird bereits verwendet",
+		"too_long":              "{0} ist zu lang",
+		"password.Required":     "Das Passwort ist ein Pflichtfeld",
+		"password.MinLength":    "Das Passwort muss mindestens {0} Zeichen lang sein",
+		"password.MaxLength":    "Das Passwort darf höchstens {0} Zeichen lang sein",
+		"password.MaxBytes":     "Das Passwort darf höchstens {0} Bytes lang sein, weniger Zeichen, wenn es keine lateinischen Buchstaben sind",
+		"password.Uppercase":    "Das Passwort muss einen Großbuchstaben enthalten",
+		"password.Lowercase":    "Das Passwort muss einen Kleinbuchstaben enthalten",
+		"password.Digit":        "Das Passwort muss eine Ziffer enthalten",
+		"password.Symbol":       "Das Passwort muss ein Sonderzeichen enthalten",
+		"password.PersonalInfo": "Das Passwort darf weder Ihre E-Mail-Adresse noch Ihren Namen enthalten",
+		"password.Common":       "Das Passwort ist zu verbreitet, wählen Sie ein weniger vorhersehbares",
+		"password.Breached":     "Das Passwort ist in einem Datenleck aufgetaucht, wählen Sie ein anderes",
+		"password.Reused":       "Das Passwort wurde kürzlich verwendet, wählen Sie ein anderes",
+	},
+}
+
+// translators holds a translator for every locale of messageCatalog, English
+// being the fallback
+var translators = newTranslators()
+
+// validate validates users, with the country tag accepting ISO 3166-1
+// alpha-2 codes
+var validate = newValidator()
+
+func newTranslators() *ut.UniversalTranslator {
+	english := en.New()
+	translators := ut.New(english, english, fr.New(), es.New(), de.New())
+	for locale, messages := range messageCatalog {
+		trans, found := translators.GetTranslator(locale)
+		if !found {
+			panic("no translator for locale " + locale)
+		}
+		for key, text := range messages {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return translators
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	if err := v.RegisterValidation("country", validateCountry); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+type localesKey struct{}
+
+// WithLocales returns a copy of ctx asking for messages in the first of
+// locales, such as "fr_CA" or "fr", that has translations
+func WithLocales(ctx context.Context, locales ...string) context.Context {
+	return context.WithValue(ctx, localesKey{}, locales)
+}
+
+// translatorFrom returns the translator of the locales of ctx, the English
+// one when none has translations
+func translatorFrom(ctx context.Context) ut.Translator {
+	locales, _ := ctx.Value(localesKey{}).([]string)
+	trans, _ := translators.FindTranslator(locales...)
+	return trans
+}
+
+// translateFieldError describes the failed validation of fe, naming tags
+// without a message of their own invalid
+func translateFieldError(trans ut.Translator, fe validator.FieldError) string {
+	field := casee.ToSnakeCase(fe.StructField())
+	name, err := trans.T("field." + field)
+	if err != nil {
+		name = inflect.Humanize(field)
+	}
+
+	key := fe.Tag()
+	if (key == "min" || key == "max") && fe.Kind() == reflect.String {
+		key += "_length"
+	}
+	message, err := trans.T(key, name, fe.Param())
+	if err != nil {
+		message, _ = trans.T("invalid", name)
+	}
+	return message
+}
+
+// translateConstraintError describes the constraint violation of e
+func translateConstraintError(trans ut.Translator, e *ConstraintError) string {
+	name, err := trans.T("field." + e.Field)
+	if err != nil {
+		name = inflect.Humanize(e.Field)
+	}
+	key := "invalid"
+	switch e.Validation {
+	case "Taken":
+		key = "taken"
+	case "Required":
+		key = "required"
+	case "Max":
+		key = "too_long"
+	}
+	message, err := trans.T(key, name)
+	if err != nil {
+		message, _ = trans.T("invalid", name)
+	}
+	return message
+}
+
+// translateViolation describes violation, in English when trans has no
+// translation for its rule
+func translateViolation(trans ut.Translator, violation PasswordViolation) string {
+	message, err := trans.T("password."+violation.Rule, violation.Param)
+	if err != nil {
+		return "Password " + violation.Message
+	}
+	return message
+}
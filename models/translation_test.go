@@ -0,0 +1,71 @@
+package models_test
+
+import (
+	"context"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("Translation", func() {
+	var (
+		store *models.MemoryStore
+		user  models.User
+	)
+
+	messages := func(ctx context.Context, in *pb.CreateUserRequest) map[string]string {
+		response, err := user.CreateInDB(ctx, in, store)
+		Expect(err).To(HaveOccurred())
+		messages := map[string]string{}
+		for _, field := range response.Fields {
+			messages[field.Name+":"+field.Validation] = field.Message
+		}
+		return messages
+	}
+
+	BeforeEach(func() {
+		store = models.NewMemoryStore()
+		models.PasswordCost = bcrypt.MinCost
+	})
+
+	AfterEach(func() {
+		models.PasswordCost = bcrypt.DefaultCost
+	})
+
+	It("translates validation and password messages to the first supported locale", func() {
+		in := &pb.CreateUserRequest{Email: "ada.example.com", Password: "ada", Age: 200, Role: "admin"}
+		ctx := models.WithLocales(context.Background(), "es_MX", "es", "en")
+		Expect(messages(ctx, in)).To(Equal(map[string]string{
+			"first_name:Required": "El campo nombre es obligatorio",
+			"last_name:Required":  "El campo apellido es obligatorio",
+			"email:Email":         "El campo correo electrónico debe ser una dirección de correo electrónico válida",
+			"password:MinLength":  "La contraseña debe tener al menos 8 caracteres",
+			"age:Max":             "El campo edad debe ser como máximo 120",
+			"city:Required":       "El campo ciudad es obligatorio",
+			"country:Required":    "El campo país es obligatorio",
+		}))
+	})
+
+	It("falls back to English", func() {
+		in := &pb.CreateUserRequest{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", Password: "s3cret-Passw0rd", Age: 36, Role: "admin",
+			Location: &pb.CreateUserRequest_Location{City: "London", Country: "ZZ"}}
+		ctx := models.WithLocales(context.Background(), "pt_BR", "pt")
+		Expect(messages(ctx, in)).To(Equal(map[string]string{
+			"country:Country": "Country must be an ISO 3166-1 alpha-2 country code",
+		}))
+	})
+
+	Describe("IsCountryCode", func() {
+		It("accepts assigned ISO 3166-1 alpha-2 codes in any case", func() {
+			Expect(models.IsCountryCode("GB")).To(BeTrue())
+			Expect(models.IsCountryCode("fr")).To(BeTrue())
+			Expect(models.IsCountryCode("UK")).To(BeFalse())
+			Expect(models.IsCountryCode("GBR")).To(BeFalse())
+			Expect(models.IsCountryCode("")).To(BeFalse())
+		})
+	})
+})
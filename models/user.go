@@ -9,89 +9,183 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"perScoreAuth/metrics"
 	pb "perScoreAuth/perScoreProto/user"
+	"strings"
+	"time"
 
 	"github.com/chuckpreslar/inflect"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres" // postgres dialect for gorm
 	"github.com/pinzolo/casee"
+	log "github.com/sirupsen/logrus"
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
 // Key ...
 const Key = "fkzfgk0FY2CaYJhyXbshnPJaRrFtCwfj"
 
+// SessionDuration is how long a session token is valid
+const SessionDuration = 10 * time.Minute
+
+// Roles users can sign up with
+var Roles = []string{"admin", "questioner", "responder"}
+
+// roleLabel returns role when it is a known role and "other" otherwise, to
+// keep metric labels bounded
+func roleLabel(role string) string {
+	for _, known := range Roles {
+		if role == known {
+			return role
+		}
+	}
+	return "other"
+}
+
 // User ...
 type User struct {
 	gorm.Model
-	FirstName string `validate:"required"`
-	LastName  string `validate:"required"`
-	Email     string `gorm:"unique"`
+	FirstName string `validate:"required,max=100"`
+	LastName  string `validate:"required,max=100"`
+	Email     string `gorm:"unique" validate:"required,max=254,email"`
 	Password  string `validate:"required"`
-	Age       int32  `validate:"required"`
+	Age       int32  `validate:"required,min=13,max=120"`
 	Role      string `validate:"required"`
 	Location  Location
+	// DisabledAt is set when an administrator disabled the account
+	DisabledAt *time.Time
+	// PasswordChangedAt is when the password was last set, unknown for
+	// users created before it was recorded
+	PasswordChangedAt *time.Time
+	// MustChangePassword is set when an administrator requires a new
+	// password at the next login
+	MustChangePassword bool
 }
 
 // Location ...
 type Location struct {
 	gorm.Model
-	City    string `validate:"required"`
-	Country string `validate:"required"`
+	City string `validate:"required,max=100"`
+	// Country is an ISO 3166-1 alpha-2 code, stored upper case
+	Country string `validate:"required,country"`
 	UserID  uint
 }
 
 // CreateInDB ...
-func (user User) CreateInDB(ctx context.Context, in *pb.CreateUserRequest, db *gorm.DB) (*pb.CreateUserResponse, error) {
+func (user User) CreateInDB(ctx context.Context, in *pb.CreateUserRequest, store Store) (*pb.CreateUserResponse, error) {
+	return createInDB(ctx, in, "", store)
+}
+
+// createInDB creates the user for in, storing passwordHash instead of a hash
+// of in.Password when it is set
+func createInDB(ctx context.Context, in *pb.CreateUserRequest, passwordHash string, store Store) (*pb.CreateUserResponse, error) {
 	var response = new(pb.CreateUserResponse)
 	var fieldResponses []*pb.CreateUserResponse_Field
 
-	fieldResponses, err := CreateUser(in, fieldResponses, db)
+	fieldResponses, err := createUser(ctx, in, passwordHash, fieldResponses, store)
 
 	if err != nil {
 		response.Status = "FAILURE"
 		response.Token = ""
 		response.Message = "Signup failed. Please try again."
 		response.Fields = fieldResponses
+		reason := "database_error"
+		switch {
+		case err == ErrEmailTaken:
+			reason = "email_taken"
+		case len(fieldResponses) > 0:
+			reason = "validation_failed"
+		}
+		RecordAuditEvent(ctx, store, AuditSignup, in.Email, AuditFailure, reason)
+		metrics.ObserveSignup(roleLabel(in.Role), AuditFailure)
 	} else {
 		response.Status = "SUCCESS"
 		response.Token = ""
 		response.Message = "You have signed up successfully!"
+		metrics.ObserveSignup(roleLabel(in.Role), AuditSuccess)
 	}
 
 	return response, err
 }
 
 // CreateSession ...
-func (user User) CreateSession(sctx context.Context, in *pb.GetSessionRequest, db *gorm.DB) (*pb.GetSessionResponse, error) {
-	var err error
-	var sessionInMinutes = "10"
+func (user User) CreateSession(sctx context.Context, in *pb.GetSessionRequest, store Store) (*pb.GetSessionResponse, error) {
 	var response = new(pb.GetSessionResponse)
-	result := db.Where("email = ? ", in.Email).First(&user).RecordNotFound()
-	if result == false {
-		if in.Password == Decrypt(user.Password) {
-			result = false
-		} else {
-			result = true
+	var err error
+	user, reason := authenticate(store, in.Email, in.Password)
+
+	if reason == "" {
+		if changeReason := passwordChangeReason(AuditContextFrom(sctx).Tenant, user, time.Now()); changeReason != "" {
+			response.Status = "PASSWORD_CHANGE_REQUIRED"
+			response.Token = ""
+			response.Message = "Your password must be changed before you can log in."
+			response.PasswordChangeRequired = true
+			RecordAuditEvent(sctx, store, AuditLoginBlocked, in.Email, AuditFailure, changeReason)
+			return response, ErrPasswordChangeRequired
 		}
 	}
 
-	plaintext := user.Email + "," + user.Role + "," + sessionInMinutes
-
-	if result == true {
+	if reason != "" {
 		response.Status = "FAILURE"
 		response.Token = ""
 		response.Message = "Invalid email and password combination!"
 		err = errors.New(response.Message)
+		RecordAuditEvent(sctx, store, AuditLoginFailure, in.Email, AuditFailure, reason)
 	} else {
 		response.Status = "SUCCESS"
-		response.Token = Encrypt(plaintext)
+		response.Token = IssueToken(user.Email, user.Role, SessionDuration, time.Now())
 		response.Message = "Logged in successfully!"
 		err = nil
+		RecordAuditEvent(sctx, store, AuditLoginSuccess, in.Email, AuditSuccess, "")
+		metrics.SessionStarted(time.Now().Add(SessionDuration))
 	}
 	return response, err
 }
 
+// authenticate returns the user with email when password is theirs, and the
+// reason it is not otherwise. Passwords stored with an outdated algorithm are
+// upgraded on the way.
+func authenticate(sessions SessionStore, email, password string) (User, string) {
+	user, err := sessions.FindUserByEmail(email)
+	switch {
+	case err == ErrUserNotFound:
+		return user, "unknown_email"
+	case err != nil:
+		log.Errorf("Error finding user: %+v", err)
+		return user, "database_error"
+	case user.DisabledAt != nil:
+		return user, "disabled"
+	}
+
+	match, rehash, err := VerifyPassword(user.Password, password)
+	switch {
+	case err != nil:
+		log.WithFields(log.Fields{"user_id": user.ID, "error": err}).Error("Stored password hash cannot be read")
+		return user, "unreadable_password"
+	case !match:
+		return user, "wrong_password"
+	}
+	if rehash {
+		upgradePasswordHash(sessions, user, password)
+	}
+	return user, ""
+}
+
+// upgradePasswordHash replaces the stored hash of user with one of the
+// current algorithm. Failing to do so does not fail the login.
+func upgradePasswordHash(sessions SessionStore, user User, password string) {
+	logger := log.WithFields(log.Fields{"user_id": user.ID, "from": PasswordAlgorithm(user.Password)})
+	hashed, err := HashPassword(password)
+	if err == nil {
+		err = sessions.UpdatePassword(user.ID, hashed)
+	}
+	if err != nil {
+		logger.WithField("error", err).Warn("Upgrading password hash failed")
+		return
+	}
+	logger.Info("Upgraded password hash")
+}
+
 // Encrypt ...
 func Encrypt(text string) string {
 	key := []byte(Key)
@@ -116,50 +210,73 @@ func Encrypt(text string) string {
 	return base64.URLEncoding.EncodeToString(ciphertext)
 }
 
-// CreateUser ...
-func CreateUser(in *pb.CreateUserRequest, fieldResponses []*pb.CreateUserResponse_Field, db *gorm.DB) ([]*pb.CreateUserResponse_Field, error) {
-	validate := validator.New()
-	var user User
-	user.FirstName = in.FirstName
-	user.LastName = in.LastName
-	user.Email = in.Email
-	user.Password = Encrypt(in.Password)
-	user.Age = in.Age
-	user.Role = in.Role
-
-	user.Location.City = in.Location.City
-	user.Location.Country = in.Location.Country
+// CreateUser validates in and stores the user in one transaction, see
+// insertUser. Constraint violations are returned as a *ConstraintError with
+// a field response, other failures as a *TransactionError.
+func CreateUser(in *pb.CreateUserRequest, fieldResponses []*pb.CreateUserResponse_Field, store Store) ([]*pb.CreateUserResponse_Field, error) {
+	return createUser(context.Background(), in, "", fieldResponses, store)
+}
 
-	err := validate.Struct(user)
+func createUser(ctx context.Context, in *pb.CreateUserRequest, passwordHash string, fieldResponses []*pb.CreateUserResponse_Field, store Store) ([]*pb.CreateUserResponse_Field, error) {
+	user, fieldResponses, err := newUser(ctx, in, passwordHash, fieldResponses)
 	if err != nil {
-		for _, errV := range err.(validator.ValidationErrors) {
-			fieldResponse := new(pb.CreateUserResponse_Field)
-			fieldResponse.Name = casee.ToSnakeCase(errV.StructField())
-			fieldResponse.Validation = inflect.Titleize(errV.Tag())
-			fieldResponses = append(fieldResponses, fieldResponse)
-			fmt.Println("*** Validation Errors ***")
-			fmt.Println("NAMESPACE:", errV.Namespace())
-			fmt.Println("FIELD:", errV.Field())
-			fmt.Println("TAG:", errV.Tag())
-			fmt.Println("TYPE:", errV.Type())
-			fmt.Println("VALUE:", errV.Value())
-			fmt.Println("PARAM:", errV.Param())
-			fmt.Println()
-		}
+		return fieldResponses, err
+	}
 
-		fmt.Println("Fields:", fieldResponses)
+	err = store.InTransaction(func(tx Store) error {
+		return insertUser(ctx, tx, &user)
+	})
+	switch e := err.(type) {
+	case nil, *TransactionError:
+	case *ConstraintError:
+		fieldResponses = append(fieldResponses, &pb.CreateUserResponse_Field{
+			Name:       e.Field,
+			Validation: e.Validation,
+			Message:    translateConstraintError(translatorFrom(ctx), e),
+		})
+	default:
+		err = &TransactionError{Step: "commit", Err: err}
+	}
+	return fieldResponses, err
+}
 
-		return fieldResponses, err
+// insertUser writes user with its location and role, the first entry of its
+// password history, the successful signup audit event and the
+// TopicUserCreated outbox event
+func insertUser(ctx context.Context, tx Store, user *User) error {
+	if err := tx.CreateUser(user); err != nil {
+		if _, ok := err.(*ConstraintError); ok {
+			return err
+		}
+		return &TransactionError{Step: "user", Err: err}
 	}
 
-	fmt.Println("Fields:", fieldResponses)
+	if PasswordHistorySize > 0 {
+		if err := tx.AddPasswordHistory(user.ID, user.Password, PasswordHistorySize); err != nil {
+			return &TransactionError{Step: "password_history", Err: err}
+		}
+	}
 
-	err = db.Create(&user).Error
-	if err != nil {
-		return fieldResponses, err
+	audit := newAuditEvent(ctx, AuditSignup, user.Email, AuditSuccess, "")
+	if err := tx.AppendAuditEvent(&audit); err != nil {
+		return &TransactionError{Step: "audit_event", Err: err}
 	}
 
-	return fieldResponses, err
+	outbox, err := newOutboxEvent(TopicUserCreated, user.Email, UserCreatedEvent{
+		ID:        user.ID,
+		Tenant:    audit.Tenant,
+		Email:     user.Email,
+		Role:      user.Role,
+		Country:   user.Location.Country,
+		CreatedAt: user.CreatedAt,
+	})
+	if err == nil {
+		err = tx.AddOutboxEvent(&outbox)
+	}
+	if err != nil {
+		return &TransactionError{Step: "outbox_event", Err: err}
+	}
+	return nil
 }
 
 // Decrypt ...
@@ -186,3 +303,74 @@ func Decrypt(cryptoText string) string {
 
 	return dataArray
 }
+
+// NewUser builds the user stored for in and validates it, appending a field
+// response for every failed validation. The password is checked against the
+// policy of the default tenant and messages are in English.
+func NewUser(in *pb.CreateUserRequest, fieldResponses []*pb.CreateUserResponse_Field) (User, []*pb.CreateUserResponse_Field, error) {
+	return newUser(context.Background(), in, "", fieldResponses)
+}
+
+// newUser is NewUser checking the password against the policy of the tenant
+// of ctx, translating messages to its locale, and storing passwordHash,
+// already normalized, instead of a hash of in.Password when it is set
+func newUser(ctx context.Context, in *pb.CreateUserRequest, passwordHash string, fieldResponses []*pb.CreateUserResponse_Field) (User, []*pb.CreateUserResponse_Field, error) {
+	trans := translatorFrom(ctx)
+	var user User
+	user.FirstName = in.FirstName
+	user.LastName = in.LastName
+	user.Email = in.Email
+	user.Password = passwordHash
+	changedAt := time.Now().UTC()
+	user.PasswordChangedAt = &changedAt
+	var policyErr error
+	if user.Password == "" && in.Password != "" {
+		owner := PasswordOwner{Email: in.Email, FirstName: in.FirstName, LastName: in.LastName}
+		if perr, ok := CheckPassword(AuditContextFrom(ctx).Tenant, in.Role, in.Password, owner).(*PasswordPolicyError); ok {
+			for _, violation := range perr.Violations {
+				fieldResponses = append(fieldResponses, &pb.CreateUserResponse_Field{
+					Name:       "password",
+					Validation: violation.Rule,
+					Message:    translateViolation(trans, violation),
+				})
+			}
+			policyErr = errPasswordPolicy
+		} else {
+			hashed, err := HashPassword(in.Password)
+			if err != nil {
+				return user, fieldResponses, err
+			}
+			user.Password = hashed
+		}
+	}
+	user.Age = in.Age
+	user.Role = in.Role
+
+	if in.Location != nil {
+		user.Location.City = in.Location.City
+		user.Location.Country = strings.ToUpper(in.Location.Country)
+	}
+
+	// A password refused by the policy is not hashed, and not reported as
+	// missing too
+	var err error
+	if policyErr != nil {
+		err = validate.StructExcept(user, "Password")
+	} else {
+		err = validate.Struct(user)
+	}
+	if err != nil {
+		for _, errV := range err.(validator.ValidationErrors) {
+			fieldResponse := new(pb.CreateUserResponse_Field)
+			fieldResponse.Name = casee.ToSnakeCase(errV.StructField())
+			fieldResponse.Validation = inflect.Titleize(errV.Tag())
+			fieldResponse.Message = translateFieldError(trans, errV)
+			fieldResponses = append(fieldResponses, fieldResponse)
+			log.WithFields(log.Fields{"field": errV.Namespace(), "tag": errV.Tag()}).Debug("User validation failed")
+		}
+	}
+	if err == nil {
+		err = policyErr
+	}
+	return user, fieldResponses, err
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUserNotFound is returned when no user has the given email
+var ErrUserNotFound = errors.New("user not found")
+
+// UserFilter selects the users returned by UserStore.ListUsers. Zero values match
+// everything except disabled users.
+type UserFilter struct {
+	Role            string
+	Emails          []string
+	CreatedBefore   time.Time
+	IncludeDisabled bool
+	Limit           int
+}
+
+// selects reports whether the filter narrows the users down
+func (filter UserFilter) selects() bool {
+	return filter.Role != "" || len(filter.Emails) > 0 || !filter.CreatedBefore.IsZero()
+}
+
+// IsRole reports whether role is one of Roles
+func IsRole(role string) bool {
+	return roleLabel(role) == role
+}
+
+// DisableUser prevents the user with email from logging in
+func DisableUser(ctx context.Context, store Store, email string) error {
+	user, err := store.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.DisabledAt != nil {
+		return nil
+	}
+
+	err = store.UpdateDisabledAt(user.ID, time.Now().UTC())
+	recordAdminAuditEvent(ctx, store, AuditAccountDisabled, email, err)
+	return err
+}
+
+// SetUserRole changes the role of the user with email
+func SetUserRole(ctx context.Context, store Store, email, role string) error {
+	if !IsRole(role) {
+		return fmt.Errorf("unknown role %q, expected one of %v", role, Roles)
+	}
+	user, err := store.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	err = store.UpdateRole(user.ID, role)
+	recordAdminAuditEvent(ctx, store, AuditRoleChange, email, err)
+	return err
+}
+
+// ResetPassword replaces the password of the user with email. The password
+// must comply with the policy of the user's role in the tenant of ctx, and
+// not be one of the user's recent passwords.
+func ResetPassword(ctx context.Context, store Store, email, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	user, err := store.FindUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := checkNewPassword(ctx, store, user, password); err != nil {
+		return err
+	}
+
+	err = storePassword(store, user.ID, password)
+	recordAdminAuditEvent(ctx, store, AuditPasswordChange, email, err)
+	return err
+}
+
+func recordAdminAuditEvent(ctx context.Context, audit AuditStore, eventType, subject string, err error) {
+	if err != nil {
+		RecordAuditEvent(ctx, audit, eventType, subject, AuditFailure, "database_error")
+	} else {
+		RecordAuditEvent(ctx, audit, eventType, subject, AuditSuccess, "")
+	}
+}
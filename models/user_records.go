@@ -0,0 +1,350 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "perScoreAuth/perScoreProto/user"
+)
+
+// Formats of user import and export files
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// UserImportFields are the fields read from import files
+// A user has either a password or a password_hash from another system, see
+// NormalizePasswordHash.
+var UserImportFields = []string{"first_name", "last_name", "email", "password", "password_hash", "age", "role", "city", "country"}
+
+// UserExportFields are the fields that can be exported. Passwords never are.
+var UserExportFields = []string{"id", "email", "first_name", "last_name", "age", "role", "city", "country", "created_at", "disabled_at"}
+
+// UserRecord is a user read from an import file. Err is set when the line
+// could not be read.
+type UserRecord struct {
+	Line    int
+	Request *pb.CreateUserRequest
+	// PasswordHash is the normalized password_hash, stored as is
+	PasswordHash string
+	Err          error
+}
+
+// UserImportResult is the outcome of importing one record
+type UserImportResult struct {
+	Line   int
+	Email  string
+	Fields []*pb.CreateUserResponse_Field
+	Err    error
+}
+
+// FormatFromPath returns the format of a file from its extension
+func FormatFromPath(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, nil
+	default:
+		return "", fmt.Errorf("cannot tell the format of %s, expected a .csv or .jsonl file", path)
+	}
+}
+
+// ReadUserRecords reads users from a CSV file with a header row naming the
+// columns, or from JSON Lines with one object per line. Columns are named
+// after UserImportFields.
+func ReadUserRecords(r io.Reader, format string) ([]UserRecord, error) {
+	switch format {
+	case FormatCSV:
+		return readUserCSV(r)
+	case FormatJSONL:
+		return readUserJSONL(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+func readUserCSV(r io.Reader) ([]UserRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the header: %v", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !isUserImportField(header[i]) {
+			return nil, fmt.Errorf("unknown column %q, expected some of %v", column, UserImportFields)
+		}
+	}
+
+	var records []UserRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			records = append(records, UserRecord{Line: parseErr.StartLine, Request: newImportRequest(), Err: parseErr})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading the records: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		record := UserRecord{Line: line, Request: newImportRequest()}
+		if len(row) != len(header) {
+			record.Err = fmt.Errorf("expected %d columns, got %d", len(header), len(row))
+		} else {
+			for i, value := range row {
+				if record.Err = setUserImportField(&record, header[i], value); record.Err != nil {
+					break
+				}
+			}
+		}
+		if record.Err == nil {
+			record.Err = checkImportPassword(record)
+		}
+		records = append(records, record)
+	}
+}
+
+func readUserJSONL(r io.Reader) ([]UserRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var records []UserRecord
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		record := UserRecord{Line: line, Request: newImportRequest()}
+		var object map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			record.Err = err
+		}
+		for name, value := range object {
+			if !isUserImportField(name) {
+				record.Err = fmt.Errorf("unknown field %q", name)
+				break
+			}
+			if value == nil {
+				continue
+			}
+			if record.Err = setUserImportField(&record, name, fmt.Sprint(value)); record.Err != nil {
+				break
+			}
+		}
+		if record.Err == nil {
+			record.Err = checkImportPassword(record)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+func newImportRequest() *pb.CreateUserRequest {
+	return &pb.CreateUserRequest{Location: &pb.CreateUserRequest_Location{}}
+}
+
+func isUserImportField(name string) bool {
+	for _, field := range UserImportFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func checkImportPassword(record UserRecord) error {
+	if record.PasswordHash != "" && record.Request.Password != "" {
+		return errors.New("password and password_hash are both set")
+	}
+	return nil
+}
+
+func setUserImportField(record *UserRecord, name, value string) error {
+	in := record.Request
+	value = strings.TrimSpace(value)
+	switch name {
+	case "first_name":
+		in.FirstName = value
+	case "last_name":
+		in.LastName = value
+	case "email":
+		in.Email = value
+	case "password":
+		in.Password = value
+	case "password_hash":
+		if value == "" {
+			return nil
+		}
+		hash, err := NormalizePasswordHash(value)
+		if err != nil {
+			return err
+		}
+		record.PasswordHash = hash
+	case "age":
+		if value == "" {
+			return nil
+		}
+		age, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("age %q is not a number", value)
+		}
+		in.Age = int32(age)
+	case "role":
+		in.Role = value
+	case "city":
+		in.Location.City = value
+	case "country":
+		in.Location.Country = value
+	}
+	return nil
+}
+
+// ImportUsers creates a user for every record with the same validation as
+// CreateUser. With dryRun the records are only validated and checked against
+// existing users.
+func ImportUsers(ctx context.Context, store Store, records []UserRecord, dryRun bool) []UserImportResult {
+	results := make([]UserImportResult, 0, len(records))
+	seen := map[string]int{}
+	for _, record := range records {
+		result := UserImportResult{Line: record.Line, Err: record.Err}
+		if record.Request != nil {
+			result.Email = record.Request.Email
+		}
+		if result.Err == nil {
+			result.Fields, result.Err = importUser(ctx, store, record, dryRun, seen)
+		}
+		if result.Err == nil {
+			seen[strings.ToLower(result.Email)] = record.Line
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func importUser(ctx context.Context, store Store, record UserRecord, dryRun bool, seen map[string]int) ([]*pb.CreateUserResponse_Field, error) {
+	in := record.Request
+	if line, ok := seen[strings.ToLower(in.Email)]; ok && in.Email != "" {
+		return nil, fmt.Errorf("email is already used on line %d", line)
+	}
+
+	if dryRun {
+		_, fields, err := newUser(ctx, in, record.PasswordHash, nil)
+		if err != nil {
+			return fields, errors.New("validation failed")
+		}
+		if _, err := store.FindUserByEmail(in.Email); err == nil {
+			return nil, errors.New("a user with this email already exists")
+		} else if err != ErrUserNotFound {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	response, err := createInDB(ctx, in, record.PasswordHash, store)
+	if err != nil && len(response.Fields) > 0 {
+		return response.Fields, errors.New("validation failed")
+	}
+	return nil, err
+}
+
+// WriteUsers writes the fields of users as CSV with a header row, or as JSON
+// Lines. All of UserExportFields are written when fields is empty.
+func WriteUsers(w io.Writer, format string, fields []string, users []User) error {
+	if len(fields) == 0 {
+		fields = UserExportFields
+	}
+	for _, field := range fields {
+		if !isUserExportField(field) {
+			return fmt.Errorf("unknown field %q, expected some of %v", field, UserExportFields)
+		}
+	}
+
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		writer.Write(fields)
+		for _, user := range users {
+			row := make([]string, len(fields))
+			for i, field := range fields {
+				if value := userExportValue(user, field); value != nil {
+					row[i] = fmt.Sprint(value)
+				}
+			}
+			writer.Write(row)
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, user := range users {
+			object := map[string]interface{}{}
+			for _, field := range fields {
+				object[field] = userExportValue(user, field)
+			}
+			if err := encoder.Encode(object); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatJSONL)
+	}
+}
+
+func isUserExportField(name string) bool {
+	for _, field := range UserExportFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+func userExportValue(user User, field string) interface{} {
+	switch field {
+	case "id":
+		return user.ID
+	case "email":
+		return user.Email
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "age":
+		return user.Age
+	case "role":
+		return user.Role
+	case "city":
+		return user.Location.City
+	case "country":
+		return user.Location.Country
+	case "created_at":
+		return user.CreatedAt.UTC().Format(time.RFC3339)
+	case "disabled_at":
+		if user.DisabledAt == nil {
+			return nil
+		}
+		return user.DisabledAt.UTC().Format(time.RFC3339)
+	}
+	return nil
+}
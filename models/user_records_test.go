@@ -0,0 +1,143 @@
+package models_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UserRecords", func() {
+	ada := &pb.CreateUserRequest{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		Password:  "secret",
+		Age:       36,
+		Role:      "admin",
+		Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
+	}
+
+	Describe("ReadUserRecords", func() {
+		It("reads CSV with the columns named in the header", func() {
+			records, err := models.ReadUserRecords(strings.NewReader(
+				"email,first_name,last_name,password,age,role,city,country\n"+
+					"ada@example.com,Ada,Lovelace,secret,36,admin,London,GB\n"+
+					"bob@example.com,Bob,,x,old,responder,Paris,France\n"+
+					"carl@example.com,Carl\n"), models.FormatCSV)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+
+			Expect(records[0].Line).To(Equal(2))
+			Expect(records[0].Err).NotTo(HaveOccurred())
+			Expect(records[0].Request).To(Equal(ada))
+			Expect(records[1].Line).To(Equal(3))
+			Expect(records[1].Err).To(MatchError(ContainSubstring("age")))
+			Expect(records[2].Err).To(MatchError(ContainSubstring("columns")))
+		})
+
+		It("reports malformed CSV rows and reads on", func() {
+			records, err := models.ReadUserRecords(strings.NewReader("email,age\na@b.c,3\nx\"y,4\nd@e.f,5\n"), models.FormatCSV)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+
+			Expect(records[0].Err).NotTo(HaveOccurred())
+			Expect(records[1].Line).To(Equal(3))
+			Expect(records[1].Err).To(MatchError(ContainSubstring("bare \"")))
+			Expect(records[2].Line).To(Equal(4))
+			Expect(records[2].Err).NotTo(HaveOccurred())
+		})
+
+		It("rejects unknown CSV columns", func() {
+			_, err := models.ReadUserRecords(strings.NewReader("email,nickname\n"), models.FormatCSV)
+			Expect(err).To(MatchError(ContainSubstring("nickname")))
+		})
+
+		It("reads and normalizes password hashes", func() {
+			records, err := models.ReadUserRecords(strings.NewReader(
+				"email,password,password_hash\n"+
+					"ada@example.com,,$2a$04$yOIBdUJEyL5X1mXb/8VKoeS6e1pSePZxZBQu62Hk6NeGV/mS4D8Ca\n"+
+					"bob@example.com,,md5$abc\n"+
+					"carl@example.com,secret,sha1$pepper$8a36b93fd4b1e1e0e6a1a2b4a56d3e6a7f0c1d2e\n"), models.FormatCSV)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+
+			Expect(records[0].Err).NotTo(HaveOccurred())
+			Expect(records[0].PasswordHash).To(Equal("bcrypt$$2a$04$yOIBdUJEyL5X1mXb/8VKoeS6e1pSePZxZBQu62Hk6NeGV/mS4D8Ca"))
+			Expect(records[1].Err).To(MatchError("unknown password hash format"))
+			Expect(records[2].Err).To(MatchError(ContainSubstring("both")))
+		})
+
+		It("reads JSON Lines", func() {
+			records, err := models.ReadUserRecords(strings.NewReader(
+				`{"email":"ada@example.com","first_name":"Ada","last_name":"Lovelace","password":"secret","age":36,"role":"admin","city":"London","country":"GB"}`+"\n\n"+
+					`{"email":"bob@example.com","age":"41","nickname":"bobby"}`+"\n"+
+					`{"email":`+"\n"), models.FormatJSONL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+
+			Expect(records[0].Err).NotTo(HaveOccurred())
+			Expect(records[0].Request).To(Equal(ada))
+			Expect(records[1].Line).To(Equal(3))
+			Expect(records[1].Err).To(MatchError(ContainSubstring("nickname")))
+			Expect(records[2].Line).To(Equal(4))
+			Expect(records[2].Err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ImportUsers", func() {
+		It("reports records that could not be read", func() {
+			records := []models.UserRecord{{Line: 3, Request: &pb.CreateUserRequest{Email: "bob@example.com"}, Err: models.ErrUserNotFound}}
+			results := models.ImportUsers(context.Background(), nil, records, true)
+			Expect(results).To(Equal([]models.UserImportResult{{Line: 3, Email: "bob@example.com", Err: models.ErrUserNotFound}}))
+		})
+	})
+
+	Describe("WriteUsers", func() {
+		disabledAt := time.Date(2017, 11, 2, 8, 0, 0, 0, time.UTC)
+		users := []models.User{{
+			FirstName:  "Ada",
+			LastName:   "Lovelace",
+			Email:      "ada@example.com",
+			Password:   "encrypted",
+			Age:        36,
+			Role:       "admin",
+			Location:   models.Location{City: "London", Country: "GB"},
+			DisabledAt: &disabledAt,
+		}}
+		users[0].ID = 7
+
+		It("writes the selected fields as CSV", func() {
+			var out bytes.Buffer
+			Expect(models.WriteUsers(&out, models.FormatCSV, []string{"id", "email", "city", "disabled_at"}, users)).To(Succeed())
+			Expect(out.String()).To(Equal("id,email,city,disabled_at\n7,ada@example.com,London,2017-11-02T08:00:00Z\n"))
+		})
+
+		It("writes JSON Lines without passwords", func() {
+			var out bytes.Buffer
+			Expect(models.WriteUsers(&out, models.FormatJSONL, nil, users)).To(Succeed())
+			Expect(out.String()).NotTo(ContainSubstring("encrypted"))
+			Expect(out.String()).To(ContainSubstring(`"age":36`))
+			Expect(out.String()).To(ContainSubstring(`"email":"ada@example.com"`))
+		})
+
+		It("rejects unknown fields", func() {
+			Expect(models.WriteUsers(&bytes.Buffer{}, models.FormatCSV, []string{"password"}, users)).To(HaveOccurred())
+		})
+	})
+
+	Describe("FormatFromPath", func() {
+		It("uses the extension", func() {
+			Expect(models.FormatFromPath("users.CSV")).To(Equal(models.FormatCSV))
+			Expect(models.FormatFromPath("export/users.jsonl")).To(Equal(models.FormatJSONL))
+			_, err := models.FormatFromPath("users.xlsx")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
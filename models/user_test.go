@@ -2,53 +2,312 @@ package models_test
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
+	"errors"
 	"strings"
+	"time"
 
 	"perScoreAuth/models"
-
 	pb "perScoreAuth/perScoreProto/user"
 
-	mocket "github.com/Selvatico/go-mocket"
-	"github.com/bxcodec/faker"
-	"github.com/jinzhu/gorm"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// failingOutboxStore is a MemoryStore failing to add outbox events
+type failingOutboxStore struct {
+	*models.MemoryStore
+}
+
+func (s failingOutboxStore) AddOutboxEvent(event *models.OutboxEvent) error {
+	return errors.New("outbox unavailable")
+}
+
+func (s failingOutboxStore) InTransaction(fn func(tx models.Store) error) error {
+	return s.MemoryStore.InTransaction(func(tx models.Store) error {
+		return fn(failingOutboxStore{tx.(*models.MemoryStore)})
+	})
+}
+
 var _ = Describe("User", func() {
-	Describe("CreateInDb", func() {
-		mocket.Catcher.Register()
-		var user models.User
-		var userRequest pb.CreateUserRequest
-		var userRequestLocation pb.CreateUserRequest_Location
-
-		db, err := gorm.Open("postgres", "host=localhost user=perscoreauth dbname=per_score_auth sslmode=disable password=perscoreauth-dm")
-		if err != nil {
-			fmt.Println("Error:", err)
+	var (
+		store *models.MemoryStore
+		ctx   context.Context
+		user  models.User
+	)
+
+	signup := func() *pb.CreateUserRequest {
+		return &pb.CreateUserRequest{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     "ada@example.com",
+			Password:  "s3cret-Passw0rd",
+			Age:       36,
+			Role:      "admin",
+			Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
 		}
+	}
 
-		userRequestfake := faker.FakeData(&userRequest)
-		userRequestLocationfake := faker.FakeData(&userRequestLocation)
-		if userRequestfake != nil {
-			fmt.Println(userRequestfake)
+	auditReasons := func(eventType string) []string {
+		events, err := store.ListAuditEvents(models.AuditEventFilter{Type: eventType})
+		Expect(err).NotTo(HaveOccurred())
+		var reasons []string
+		for _, event := range events {
+			reasons = append(reasons, event.Outcome+":"+event.Reason)
 		}
-		if userRequestLocationfake != nil {
-			fmt.Println(userRequestLocationfake)
+		return reasons
+	}
+
+	BeforeEach(func() {
+		store = models.NewMemoryStore()
+		ctx = context.Background()
+		models.PasswordCost = bcrypt.MinCost
+	})
+
+	AfterEach(func() {
+		models.PasswordCost = bcrypt.DefaultCost
+	})
+
+	Describe("CreateInDB", func() {
+		It("stores the user with a hashed password", func() {
+			response, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Status).To(Equal("SUCCESS"))
+
+			created, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created.ID).NotTo(BeZero())
+			Expect(created.Location.City).To(Equal("London"))
+			Expect(models.PasswordAlgorithm(created.Password)).To(Equal(models.PasswordBcrypt))
+			Expect(auditReasons(models.AuditSignup)).To(Equal([]string{"success:"}))
+		})
+
+		It("announces the user in the outbox", func() {
+			acme := models.WithAuditContext(ctx, models.AuditContext{Tenant: "acme"})
+			_, err := user.CreateInDB(acme, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+
+			events, err := store.PendingOutboxEvents(10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Topic).To(Equal(models.TopicUserCreated))
+			Expect(events[0].Key).To(Equal("ada@example.com"))
+			var payload models.UserCreatedEvent
+			Expect(json.Unmarshal([]byte(events[0].Payload), &payload)).To(Succeed())
+			Expect(payload.ID).NotTo(BeZero())
+			Expect(payload.Tenant).To(Equal("acme"))
+			Expect(payload.Role).To(Equal("admin"))
+			Expect(payload.Country).To(Equal("GB"))
+		})
+
+		It("rolls the user back when a later write fails", func() {
+			failing := failingOutboxStore{store}
+			response, err := user.CreateInDB(ctx, signup(), failing)
+			Expect(err).To(BeAssignableToTypeOf(&models.TransactionError{}))
+			Expect(err.(*models.TransactionError).Step).To(Equal("outbox_event"))
+			Expect(response.Status).To(Equal("FAILURE"))
+			Expect(response.Fields).To(BeEmpty())
+
+			_, err = store.FindUserByEmail("ada@example.com")
+			Expect(err).To(Equal(models.ErrUserNotFound))
+			history, err := store.PasswordHistory(1, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(history).To(BeEmpty())
+			Expect(auditReasons(models.AuditSignup)).To(Equal([]string{"failure:database_error"}))
+		})
+
+		It("reports the fields that failed validation", func() {
+			in := signup()
+			in.Password = ""
+			in.Location = nil
+			response, err := user.CreateInDB(ctx, in, store)
+			Expect(err).To(HaveOccurred())
+			Expect(response.Status).To(Equal("FAILURE"))
+
+			var names []string
+			for _, field := range response.Fields {
+				names = append(names, field.Name+":"+field.Validation)
+			}
+			Expect(names).To(ConsistOf("password:Required", "city:Required", "country:Required"))
+			Expect(auditReasons(models.AuditSignup)).To(Equal([]string{"failure:validation_failed"}))
+		})
+
+		It("checks the email, age, country and name lengths", func() {
+			in := signup()
+			in.Email = "ada.example.com"
+			in.Age = 12
+			in.FirstName = strings.Repeat("a", 101)
+			in.Location.Country = "UK"
+			response, err := user.CreateInDB(ctx, in, store)
+			Expect(err).To(HaveOccurred())
+
+			messages := map[string]string{}
+			for _, field := range response.Fields {
+				messages[field.Name+":"+field.Validation] = field.Message
+			}
+			Expect(messages).To(Equal(map[string]string{
+				"email:Email":     "Email must be a valid email address",
+				"age:Min":         "Age must be at least 13",
+				"first_name:Max":  "First name must be at most 100 characters long",
+				"country:Country": "Country must be an ISO 3166-1 alpha-2 country code",
+			}))
+
+			in = signup()
+			in.Age = 121
+			response, err = user.CreateInDB(ctx, in, store)
+			Expect(err).To(HaveOccurred())
+			Expect(response.Fields).To(HaveLen(1))
+			Expect(response.Fields[0].Message).To(Equal("Age must be at most 120"))
+		})
+
+		It("stores the country upper case", func() {
+			in := signup()
+			in.Location.Country = "gb"
+			_, err := user.CreateInDB(ctx, in, store)
+			Expect(err).NotTo(HaveOccurred())
+
+			created, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created.Location.Country).To(Equal("GB"))
+		})
+
+		It("reports the rules the password breaks", func() {
+			policies := models.PasswordPolicies
+			defer func() { models.PasswordPolicies = policies }()
+			models.PasswordPolicies = models.NewPasswordPolicySet(models.DefaultPasswordPolicy)
+			models.PasswordPolicies.Set("acme", "admin", models.PasswordPolicy{MinLength: 20, RequireSymbol: true})
+
+			in := signup()
+			in.Password = "lovelace"
+			response, err := user.CreateInDB(ctx, in, store)
+			Expect(err).To(HaveOccurred())
+			Expect(response.Fields).To(HaveLen(1))
+			Expect(response.Fields[0].Name).To(Equal("password"))
+			Expect(response.Fields[0].Validation).To(Equal("PersonalInfo"))
+			Expect(response.Fields[0].Message).To(Equal("Password must not contain your email or name"))
+
+			in.Location.City = ""
+			refused, fields, err := models.NewUser(in, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(refused.Password).To(BeEmpty())
+			var names []string
+			for _, field := range fields {
+				names = append(names, field.Name+":"+field.Validation)
+			}
+			Expect(names).To(Equal([]string{"password:PersonalInfo", "city:Required"}))
+			in.Location.City = "London"
+
+			in.Password = "s3cret-Passw0rd"
+			acme := models.WithAuditContext(ctx, models.AuditContext{Tenant: "acme"})
+			response, err = user.CreateInDB(acme, in, store)
+			Expect(err).To(HaveOccurred())
+			Expect(response.Fields).To(HaveLen(1))
+			Expect(response.Fields[0].Validation).To(Equal("MinLength"))
+
+			_, err = store.FindUserByEmail("ada@example.com")
+			Expect(err).To(Equal(models.ErrUserNotFound))
+			Expect(auditReasons(models.AuditSignup)).To(Equal([]string{"failure:validation_failed", "failure:validation_failed"}))
+		})
+
+		It("fails for a taken email", func() {
+			_, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+			response, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).To(Equal(models.ErrEmailTaken))
+			Expect(response.Status).To(Equal("FAILURE"))
+			Expect(response.Fields).To(HaveLen(1))
+			Expect(response.Fields[0].Name).To(Equal("email"))
+			Expect(response.Fields[0].Validation).To(Equal("Taken"))
+			Expect(response.Fields[0].Message).To(Equal("Email is already taken"))
+			Expect(auditReasons(models.AuditSignup)).To(Equal([]string{"failure:email_taken", "success:"}))
+		})
+	})
+
+	Describe("ResetPassword", func() {
+		It("checks the password policy", func() {
+			_, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = models.ResetPassword(ctx, store, "ada@example.com", "short")
+			Expect(err).To(BeAssignableToTypeOf(&models.PasswordPolicyError{}))
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "an0ther-Passw0rd")).To(Succeed())
+			Expect(auditReasons(models.AuditPasswordChange)).To(Equal([]string{"success:"}))
+		})
+
+		It("refuses the user's recent passwords", func() {
+			size := models.PasswordHistorySize
+			defer func() { models.PasswordHistorySize = size }()
+			models.PasswordHistorySize = 2
+
+			_, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "s3cret-Passw0rd")).To(Equal(models.ErrPasswordReused))
+
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "an0ther-Passw0rd")).To(Succeed())
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "s3cret-Passw0rd")).To(Equal(models.ErrPasswordReused))
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "a-th1rd-Passw0rd")).To(Succeed())
+
+			// The first password has left the history of two
+			Expect(models.ResetPassword(ctx, store, "ada@example.com", "s3cret-Passw0rd")).To(Succeed())
+			created, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			entries, err := store.PasswordHistory(created.ID, 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Password).To(Equal(created.Password))
+		})
+	})
+
+	Describe("CreateSession", func() {
+		login := func(password string) (*pb.GetSessionResponse, error) {
+			return user.CreateSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com", Password: password}, store)
 		}
-		// userRequest.Email = "12"
-		userRequest.Location = &userRequestLocation
-
-		Context("With valid Response", func() {
-			It("when the responds is not nil", func() {
-				response, err := user.CreateInDB(context.Background(), &userRequest, db)
-				data := strings.TrimSpace(response.Message)
-				fmt.Println("response message ::", data)
-				if err != nil {
-					fmt.Println("Error", err)
-				}
-				Expect(response).NotTo(BeNil())
-			})
+
+		BeforeEach(func() {
+			_, err := user.CreateInDB(ctx, signup(), store)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("issues a token for the right password", func() {
+			response, err := login("s3cret-Passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.Status).To(Equal("SUCCESS"))
+
+			token, err := models.VerifyToken(response.Token, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.Email).To(Equal("ada@example.com"))
+			Expect(token.Role).To(Equal("admin"))
+		})
+
+		It("rejects wrong passwords, unknown emails and disabled users", func() {
+			_, err := login("wrong")
+			Expect(err).To(HaveOccurred())
+			_, err = user.CreateSession(ctx, &pb.GetSessionRequest{Email: "bob@example.com", Password: "s3cret-Passw0rd"}, store)
+			Expect(err).To(HaveOccurred())
+			Expect(models.DisableUser(ctx, store, "ada@example.com")).To(Succeed())
+			response, err := login("s3cret-Passw0rd")
+			Expect(err).To(HaveOccurred())
+			Expect(response.Token).To(BeEmpty())
+
+			Expect(auditReasons(models.AuditLoginFailure)).To(Equal([]string{
+				"failure:disabled", "failure:unknown_email", "failure:wrong_password",
+			}))
+		})
+
+		It("upgrades legacy password hashes", func() {
+			created, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.UpdatePassword(created.ID, models.Encrypt("s3cret-Passw0rd"))).To(Succeed())
+
+			_, err = login("s3cret-Passw0rd")
+			Expect(err).NotTo(HaveOccurred())
+			upgraded, err := store.FindUserByEmail("ada@example.com")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(models.PasswordAlgorithm(upgraded.Password)).To(Equal(models.PasswordBcrypt))
+
+			_, err = login("s3cret-Passw0rd")
+			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 })
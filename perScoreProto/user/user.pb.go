@@ -5,19 +5,29 @@
 Package user is a generated protocol buffer package.
 
 It is generated from these files:
+
 	user.proto
 
 It has these top-level messages:
+
 	CreateUserRequest
 	CreateUserResponse
 	GetSessionRequest
 	GetSessionResponse
+	ChangePasswordRequest
+	ChangePasswordResponse
+	RequirePasswordChangeRequest
+	RequirePasswordChangeResponse
+	ListAuditEventsRequest
+	AuditEvent
+	ListAuditEventsResponse
 */
 package user
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
 	context "golang.org/x/net/context"
@@ -166,6 +176,8 @@ func (m *CreateUserResponse) GetFields() []*CreateUserResponse_Field {
 type CreateUserResponse_Field struct {
 	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Validation string `protobuf:"bytes,2,opt,name=validation" json:"validation,omitempty"`
+	// message explains the failed validation to the user
+	Message string `protobuf:"bytes,3,opt,name=message" json:"message,omitempty"`
 }
 
 func (m *CreateUserResponse_Field) Reset()                    { *m = CreateUserResponse_Field{} }
@@ -187,6 +199,13 @@ func (m *CreateUserResponse_Field) GetValidation() string {
 	return ""
 }
 
+func (m *CreateUserResponse_Field) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
 type GetSessionRequest struct {
 	Email    string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
 	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
@@ -224,6 +243,9 @@ type GetSessionResponse struct {
 	Message string                      `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
 	Token   string                      `protobuf:"bytes,3,opt,name=token" json:"token,omitempty"`
 	Fields  []*GetSessionResponse_Field `protobuf:"bytes,4,rep,name=fields" json:"fields,omitempty"`
+	// password_change_required is set instead of a token when the password
+	// expired or an administrator requires a new one, see ChangePassword
+	PasswordChangeRequired bool `protobuf:"varint,5,opt,name=password_change_required,json=passwordChangeRequired" json:"password_change_required,omitempty"`
 }
 
 func (m *GetSessionResponse) Reset()                    { *m = GetSessionResponse{} }
@@ -259,6 +281,13 @@ func (m *GetSessionResponse) GetFields() []*GetSessionResponse_Field {
 	return nil
 }
 
+func (m *GetSessionResponse) GetPasswordChangeRequired() bool {
+	if m != nil {
+		return m.PasswordChangeRequired
+	}
+	return false
+}
+
 type GetSessionResponse_Field struct {
 	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Validation string `protobuf:"bytes,2,opt,name=validation" json:"validation,omitempty"`
@@ -283,6 +312,339 @@ func (m *GetSessionResponse_Field) GetValidation() string {
 	return ""
 }
 
+type ChangePasswordRequest struct {
+	Email       string `protobuf:"bytes,1,opt,name=email" json:"email,omitempty"`
+	Password    string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
+	NewPassword string `protobuf:"bytes,3,opt,name=new_password,json=newPassword" json:"new_password,omitempty"`
+}
+
+func (m *ChangePasswordRequest) Reset()                    { *m = ChangePasswordRequest{} }
+func (m *ChangePasswordRequest) String() string            { return proto.CompactTextString(m) }
+func (*ChangePasswordRequest) ProtoMessage()               {}
+func (*ChangePasswordRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ChangePasswordRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *ChangePasswordRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func (m *ChangePasswordRequest) GetNewPassword() string {
+	if m != nil {
+		return m.NewPassword
+	}
+	return ""
+}
+
+type ChangePasswordResponse struct {
+	Status  string                          `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Message string                          `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Fields  []*ChangePasswordResponse_Field `protobuf:"bytes,3,rep,name=fields" json:"fields,omitempty"`
+}
+
+func (m *ChangePasswordResponse) Reset()                    { *m = ChangePasswordResponse{} }
+func (m *ChangePasswordResponse) String() string            { return proto.CompactTextString(m) }
+func (*ChangePasswordResponse) ProtoMessage()               {}
+func (*ChangePasswordResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ChangePasswordResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ChangePasswordResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ChangePasswordResponse) GetFields() []*ChangePasswordResponse_Field {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type ChangePasswordResponse_Field struct {
+	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Validation string `protobuf:"bytes,2,opt,name=validation" json:"validation,omitempty"`
+	Message    string `protobuf:"bytes,3,opt,name=message" json:"message,omitempty"`
+}
+
+func (m *ChangePasswordResponse_Field) Reset()         { *m = ChangePasswordResponse_Field{} }
+func (m *ChangePasswordResponse_Field) String() string { return proto.CompactTextString(m) }
+func (*ChangePasswordResponse_Field) ProtoMessage()    {}
+func (*ChangePasswordResponse_Field) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{5, 0}
+}
+
+func (m *ChangePasswordResponse_Field) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ChangePasswordResponse_Field) GetValidation() string {
+	if m != nil {
+		return m.Validation
+	}
+	return ""
+}
+
+func (m *ChangePasswordResponse_Field) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+// RequirePasswordChangeRequest selects the users who must change their
+// password at their next login. Users match all the criteria set; all is
+// required to select every user.
+type RequirePasswordChangeRequest struct {
+	Emails        []string                   `protobuf:"bytes,1,rep,name=emails" json:"emails,omitempty"`
+	Role          string                     `protobuf:"bytes,2,opt,name=role" json:"role,omitempty"`
+	CreatedBefore *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=created_before,json=createdBefore" json:"created_before,omitempty"`
+	All           bool                       `protobuf:"varint,4,opt,name=all" json:"all,omitempty"`
+}
+
+func (m *RequirePasswordChangeRequest) Reset()                    { *m = RequirePasswordChangeRequest{} }
+func (m *RequirePasswordChangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*RequirePasswordChangeRequest) ProtoMessage()               {}
+func (*RequirePasswordChangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *RequirePasswordChangeRequest) GetEmails() []string {
+	if m != nil {
+		return m.Emails
+	}
+	return nil
+}
+
+func (m *RequirePasswordChangeRequest) GetRole() string {
+	if m != nil {
+		return m.Role
+	}
+	return ""
+}
+
+func (m *RequirePasswordChangeRequest) GetCreatedBefore() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.CreatedBefore
+	}
+	return nil
+}
+
+func (m *RequirePasswordChangeRequest) GetAll() bool {
+	if m != nil {
+		return m.All
+	}
+	return false
+}
+
+type RequirePasswordChangeResponse struct {
+	Status  string `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Count   int32  `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *RequirePasswordChangeResponse) Reset()                    { *m = RequirePasswordChangeResponse{} }
+func (m *RequirePasswordChangeResponse) String() string            { return proto.CompactTextString(m) }
+func (*RequirePasswordChangeResponse) ProtoMessage()               {}
+func (*RequirePasswordChangeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *RequirePasswordChangeResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *RequirePasswordChangeResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *RequirePasswordChangeResponse) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type ListAuditEventsRequest struct {
+	User  string                     `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Type  string                     `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+	From  *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=from" json:"from,omitempty"`
+	To    *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=to" json:"to,omitempty"`
+	Limit int32                      `protobuf:"varint,5,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *ListAuditEventsRequest) Reset()                    { *m = ListAuditEventsRequest{} }
+func (m *ListAuditEventsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditEventsRequest) ProtoMessage()               {}
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ListAuditEventsRequest) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *ListAuditEventsRequest) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *ListAuditEventsRequest) GetFrom() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.From
+	}
+	return nil
+}
+
+func (m *ListAuditEventsRequest) GetTo() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.To
+	}
+	return nil
+}
+
+func (m *ListAuditEventsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type AuditEvent struct {
+	Id        uint64                     `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	Type      string                     `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+	Actor     string                     `protobuf:"bytes,3,opt,name=actor" json:"actor,omitempty"`
+	Subject   string                     `protobuf:"bytes,4,opt,name=subject" json:"subject,omitempty"`
+	PeerIp    string                     `protobuf:"bytes,5,opt,name=peer_ip,json=peerIp" json:"peer_ip,omitempty"`
+	UserAgent string                     `protobuf:"bytes,6,opt,name=user_agent,json=userAgent" json:"user_agent,omitempty"`
+	Outcome   string                     `protobuf:"bytes,7,opt,name=outcome" json:"outcome,omitempty"`
+	Reason    string                     `protobuf:"bytes,8,opt,name=reason" json:"reason,omitempty"`
+	CreatedAt *google_protobuf.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt" json:"created_at,omitempty"`
+}
+
+func (m *AuditEvent) Reset()                    { *m = AuditEvent{} }
+func (m *AuditEvent) String() string            { return proto.CompactTextString(m) }
+func (*AuditEvent) ProtoMessage()               {}
+func (*AuditEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *AuditEvent) GetId() uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *AuditEvent) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetActor() string {
+	if m != nil {
+		return m.Actor
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetSubject() string {
+	if m != nil {
+		return m.Subject
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetPeerIp() string {
+	if m != nil {
+		return m.PeerIp
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetUserAgent() string {
+	if m != nil {
+		return m.UserAgent
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetOutcome() string {
+	if m != nil {
+		return m.Outcome
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *AuditEvent) GetCreatedAt() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return nil
+}
+
+type ListAuditEventsResponse struct {
+	Status  string        `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Message string        `protobuf:"bytes,2,opt,name=message" json:"message,omitempty"`
+	Events  []*AuditEvent `protobuf:"bytes,3,rep,name=events" json:"events,omitempty"`
+}
+
+func (m *ListAuditEventsResponse) Reset()                    { *m = ListAuditEventsResponse{} }
+func (m *ListAuditEventsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListAuditEventsResponse) ProtoMessage()               {}
+func (*ListAuditEventsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *ListAuditEventsResponse) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *ListAuditEventsResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *ListAuditEventsResponse) GetEvents() []*AuditEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*CreateUserRequest)(nil), "user.CreateUserRequest")
 	proto.RegisterType((*CreateUserRequest_Location)(nil), "user.CreateUserRequest.Location")
@@ -291,6 +653,14 @@ func init() {
 	proto.RegisterType((*GetSessionRequest)(nil), "user.GetSessionRequest")
 	proto.RegisterType((*GetSessionResponse)(nil), "user.GetSessionResponse")
 	proto.RegisterType((*GetSessionResponse_Field)(nil), "user.GetSessionResponse.Field")
+	proto.RegisterType((*ChangePasswordRequest)(nil), "user.ChangePasswordRequest")
+	proto.RegisterType((*ChangePasswordResponse)(nil), "user.ChangePasswordResponse")
+	proto.RegisterType((*ChangePasswordResponse_Field)(nil), "user.ChangePasswordResponse.Field")
+	proto.RegisterType((*RequirePasswordChangeRequest)(nil), "user.RequirePasswordChangeRequest")
+	proto.RegisterType((*RequirePasswordChangeResponse)(nil), "user.RequirePasswordChangeResponse")
+	proto.RegisterType((*ListAuditEventsRequest)(nil), "user.ListAuditEventsRequest")
+	proto.RegisterType((*AuditEvent)(nil), "user.AuditEvent")
+	proto.RegisterType((*ListAuditEventsResponse)(nil), "user.ListAuditEventsResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -306,6 +676,9 @@ const _ = grpc.SupportPackageIsVersion4
 type UserClient interface {
 	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*CreateUserResponse, error)
 	GetSession(ctx context.Context, in *GetSessionRequest, opts ...grpc.CallOption) (*GetSessionResponse, error)
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error)
+	RequirePasswordChange(ctx context.Context, in *RequirePasswordChangeRequest, opts ...grpc.CallOption) (*RequirePasswordChangeResponse, error)
 }
 
 type userClient struct {
@@ -334,11 +707,41 @@ func (c *userClient) GetSession(ctx context.Context, in *GetSessionRequest, opts
 	return out, nil
 }
 
+func (c *userClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := grpc.Invoke(ctx, "/user.User/ListAuditEvents", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error) {
+	out := new(ChangePasswordResponse)
+	err := grpc.Invoke(ctx, "/user.User/ChangePassword", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userClient) RequirePasswordChange(ctx context.Context, in *RequirePasswordChangeRequest, opts ...grpc.CallOption) (*RequirePasswordChangeResponse, error) {
+	out := new(RequirePasswordChangeResponse)
+	err := grpc.Invoke(ctx, "/user.User/RequirePasswordChange", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for User service
 
 type UserServer interface {
 	CreateUser(context.Context, *CreateUserRequest) (*CreateUserResponse, error)
 	GetSession(context.Context, *GetSessionRequest) (*GetSessionResponse, error)
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, error)
+	RequirePasswordChange(context.Context, *RequirePasswordChangeRequest) (*RequirePasswordChangeResponse, error)
 }
 
 func RegisterUserServer(s *grpc.Server, srv UserServer) {
@@ -381,6 +784,60 @@ func _User_GetSession_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _User_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.User/ListAuditEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.User/ChangePassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).ChangePassword(ctx, req.(*ChangePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _User_RequirePasswordChange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequirePasswordChangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServer).RequirePasswordChange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/user.User/RequirePasswordChange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServer).RequirePasswordChange(ctx, req.(*RequirePasswordChangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _User_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "user.User",
 	HandlerType: (*UserServer)(nil),
@@ -393,6 +850,18 @@ var _User_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetSession",
 			Handler:    _User_GetSession_Handler,
 		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _User_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _User_ChangePassword_Handler,
+		},
+		{
+			MethodName: "RequirePasswordChange",
+			Handler:    _User_RequirePasswordChange_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "user.proto",
@@ -401,31 +870,59 @@ var _User_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("user.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 403 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x53, 0x3d, 0x6f, 0xdb, 0x30,
-	0x10, 0xb5, 0xac, 0x0f, 0xcb, 0xe7, 0xa5, 0x26, 0x8a, 0x96, 0x50, 0x51, 0x43, 0xd0, 0xa4, 0x49,
-	0x83, 0x0b, 0x14, 0x05, 0xda, 0xa5, 0x28, 0xd0, 0x2e, 0x45, 0x07, 0x15, 0x1d, 0x3a, 0x05, 0x8c,
-	0x7d, 0x0e, 0x84, 0x48, 0xa2, 0x43, 0x52, 0x09, 0xfc, 0x1f, 0xf2, 0x1f, 0xf2, 0x9b, 0xf2, 0x8f,
-	0x02, 0x52, 0xa4, 0x2d, 0x44, 0xf0, 0x16, 0x64, 0xbb, 0x77, 0x77, 0x7c, 0xe4, 0x7b, 0x4f, 0x02,
-	0xe8, 0x24, 0x8a, 0x62, 0x2f, 0xb8, 0xe2, 0x24, 0xd0, 0x75, 0xf6, 0x30, 0x85, 0xe5, 0x0f, 0x81,
-	0x4c, 0xe1, 0x3f, 0x89, 0xa2, 0xc4, 0x9b, 0x0e, 0xa5, 0x22, 0x1f, 0x01, 0x76, 0x95, 0x90, 0xea,
-	0xa2, 0x65, 0x0d, 0x52, 0x2f, 0xf5, 0xf2, 0x79, 0x39, 0x37, 0x9d, 0x3f, 0xac, 0x41, 0xf2, 0x01,
-	0xe6, 0x35, 0x73, 0xd3, 0xa9, 0x99, 0xc6, 0xba, 0x61, 0x86, 0x6f, 0x21, 0xc4, 0x86, 0x55, 0x35,
-	0xf5, 0xcd, 0xa0, 0x07, 0x24, 0x81, 0x78, 0xcf, 0xa4, 0xbc, 0xe3, 0x62, 0x4b, 0x83, 0xfe, 0x84,
-	0xc3, 0xe4, 0x0d, 0xf8, 0xec, 0x0a, 0x69, 0x98, 0x7a, 0x79, 0x58, 0xea, 0x92, 0x10, 0x08, 0x04,
-	0xaf, 0x91, 0x46, 0x66, 0xd3, 0xd4, 0xe4, 0x1b, 0xc4, 0x35, 0xdf, 0x30, 0x55, 0xf1, 0x96, 0xce,
-	0x52, 0x2f, 0x5f, 0xac, 0xd3, 0xc2, 0xc8, 0x19, 0x3d, 0xbf, 0xf8, 0x6d, 0xf7, 0xca, 0xe3, 0x89,
-	0xe4, 0x0b, 0xc4, 0xae, 0xab, 0xd9, 0x37, 0x95, 0x3a, 0x58, 0x5d, 0xa6, 0x26, 0x14, 0x66, 0x1b,
-	0xde, 0xb5, 0x4a, 0x1c, 0xac, 0x20, 0x07, 0xb3, 0x47, 0x0f, 0xc8, 0xf0, 0x0a, 0xb9, 0xe7, 0xad,
-	0x44, 0xf2, 0x0e, 0x22, 0xa9, 0x98, 0xea, 0xa4, 0xa5, 0xb1, 0x48, 0x13, 0x35, 0x28, 0xa5, 0x16,
-	0x64, 0x89, 0x2c, 0xd4, 0xc6, 0x28, 0x7e, 0x8d, 0xad, 0x33, 0xc6, 0x00, 0xf2, 0x19, 0xa2, 0x5d,
-	0x85, 0xf5, 0x56, 0xd2, 0x20, 0xf5, 0xf3, 0xc5, 0x7a, 0x35, 0x16, 0xd5, 0xdf, 0x58, 0xfc, 0xd4,
-	0x6b, 0xa5, 0xdd, 0x4e, 0xbe, 0x42, 0x68, 0x1a, 0x5a, 0xcd, 0x20, 0x25, 0x53, 0x93, 0x15, 0xc0,
-	0x2d, 0xab, 0xab, 0x6d, 0xef, 0x56, 0xff, 0x8e, 0x41, 0x27, 0xfb, 0x0f, 0xcb, 0x5f, 0xa8, 0xfe,
-	0xa2, 0x94, 0xda, 0x25, 0x1b, 0xfa, 0x31, 0x38, 0xef, 0x5c, 0x70, 0xd3, 0x67, 0xc1, 0xb9, 0x98,
-	0xfc, 0x53, 0x4c, 0xc6, 0xae, 0x21, 0xf7, 0xeb, 0xd8, 0x35, 0xbe, 0xf1, 0x05, 0xed, 0x5a, 0xdf,
-	0x7b, 0x10, 0xe8, 0x28, 0xc8, 0x77, 0x80, 0x53, 0x30, 0xe4, 0xfd, 0x99, 0xef, 0x2f, 0xa1, 0xe7,
-	0x32, 0xcc, 0x26, 0x9a, 0xe2, 0xf4, 0x58, 0x47, 0x31, 0x0a, 0xc3, 0x51, 0x8c, 0x75, 0x65, 0x93,
-	0xcb, 0xc8, 0xfc, 0xc0, 0x9f, 0x9e, 0x02, 0x00, 0x00, 0xff, 0xff, 0x3a, 0xb5, 0x84, 0x6e, 0xce,
-	0x03, 0x00, 0x00,
+	// 855 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x55, 0xcd, 0x8e, 0xdc, 0x44,
+	0x10, 0x8e, 0x7f, 0x66, 0x76, 0x5c, 0x0b, 0x4b, 0xd2, 0x4a, 0x66, 0x2d, 0x67, 0x37, 0x0c, 0xe6,
+	0x32, 0xe2, 0xe0, 0x48, 0x8b, 0x84, 0xc2, 0xcf, 0x65, 0x88, 0x00, 0x21, 0x05, 0xb4, 0x32, 0xe4,
+	0xc0, 0x69, 0xd4, 0x63, 0xd7, 0x0c, 0x06, 0xdb, 0xed, 0xb8, 0xdb, 0x59, 0xed, 0x03, 0x70, 0xe2,
+	0xc4, 0x13, 0xe4, 0x1d, 0x78, 0x1f, 0x9e, 0x80, 0x97, 0x40, 0xfd, 0xe7, 0x99, 0xdd, 0x99, 0xd9,
+	0xa0, 0x8d, 0xb4, 0xb7, 0xae, 0x9f, 0xfe, 0xba, 0xea, 0xab, 0xea, 0x2a, 0x80, 0x8e, 0x63, 0x9b,
+	0x34, 0x2d, 0x13, 0x8c, 0xf8, 0xf2, 0x1c, 0x7d, 0xb8, 0x62, 0x6c, 0x55, 0xe2, 0x53, 0xa5, 0x5b,
+	0x74, 0xcb, 0xa7, 0xa2, 0xa8, 0x90, 0x0b, 0x5a, 0x35, 0xda, 0x2d, 0x7e, 0xe3, 0xc2, 0x83, 0xe7,
+	0x2d, 0x52, 0x81, 0x2f, 0x39, 0xb6, 0x29, 0xbe, 0xea, 0x90, 0x0b, 0x72, 0x0a, 0xb0, 0x2c, 0x5a,
+	0x2e, 0xe6, 0x35, 0xad, 0x30, 0x74, 0x26, 0xce, 0x34, 0x48, 0x03, 0xa5, 0xf9, 0x91, 0x56, 0x48,
+	0x1e, 0x43, 0x50, 0x52, 0x6b, 0x75, 0x95, 0x75, 0x54, 0x52, 0x63, 0x7c, 0x08, 0x03, 0xac, 0x68,
+	0x51, 0x86, 0x9e, 0x32, 0x68, 0x81, 0x44, 0x30, 0x6a, 0x28, 0xe7, 0x17, 0xac, 0xcd, 0x43, 0x5f,
+	0xdf, 0xb0, 0x32, 0xb9, 0x0f, 0x1e, 0x5d, 0x61, 0x38, 0x98, 0x38, 0xd3, 0x41, 0x2a, 0x8f, 0x84,
+	0x80, 0xdf, 0xb2, 0x12, 0xc3, 0xa1, 0xf2, 0x54, 0x67, 0xf2, 0x15, 0x8c, 0x4a, 0x96, 0x51, 0x51,
+	0xb0, 0x3a, 0x3c, 0x98, 0x38, 0xd3, 0xc3, 0xb3, 0x49, 0xa2, 0xf2, 0xdd, 0x0a, 0x3f, 0x79, 0x61,
+	0xfc, 0xd2, 0xfe, 0x46, 0xf4, 0x0c, 0x46, 0x56, 0x2b, 0xd1, 0xb3, 0x42, 0x5c, 0x9a, 0xbc, 0xd4,
+	0x99, 0x84, 0x70, 0x90, 0xb1, 0xae, 0x16, 0xed, 0xa5, 0x49, 0xc8, 0x8a, 0xf1, 0xbf, 0x0e, 0x90,
+	0xcd, 0x27, 0x78, 0xc3, 0x6a, 0x8e, 0x64, 0x0c, 0x43, 0x2e, 0xa8, 0xe8, 0xb8, 0x81, 0x31, 0x92,
+	0x04, 0xaa, 0x90, 0x73, 0x99, 0x90, 0x01, 0x32, 0xa2, 0x24, 0x46, 0xb0, 0xdf, 0xb1, 0xb6, 0xc4,
+	0x28, 0x81, 0x7c, 0x06, 0xc3, 0x65, 0x81, 0x65, 0xce, 0x43, 0x7f, 0xe2, 0x4d, 0x0f, 0xcf, 0x9e,
+	0x6c, 0x27, 0xa5, 0x5f, 0x4c, 0xbe, 0x95, 0x6e, 0xa9, 0xf1, 0x8e, 0x5e, 0xc2, 0x40, 0x29, 0x64,
+	0x36, 0x1b, 0x55, 0x52, 0x67, 0xf2, 0x04, 0xe0, 0x35, 0x2d, 0x8b, 0x5c, 0xb3, 0xa5, 0xe3, 0xd8,
+	0xd0, 0x6c, 0x06, 0xe9, 0x5d, 0x09, 0x32, 0xfe, 0x05, 0x1e, 0x7c, 0x87, 0xe2, 0x27, 0xe4, 0x5c,
+	0xf2, 0x67, 0xda, 0xa1, 0x2f, 0xa9, 0xb3, 0xaf, 0xa4, 0xee, 0xb5, 0x92, 0xda, 0x02, 0x7a, 0xeb,
+	0x02, 0xc6, 0x7f, 0xba, 0x40, 0x36, 0xb1, 0xef, 0x86, 0xc8, 0xed, 0x17, 0xaf, 0x12, 0x49, 0x9e,
+	0x41, 0x68, 0xc3, 0x9e, 0x67, 0xbf, 0xd2, 0x7a, 0x85, 0xf3, 0x16, 0x5f, 0x75, 0x45, 0x8b, 0xb9,
+	0x6a, 0xc9, 0x51, 0x3a, 0xb6, 0xf6, 0xe7, 0xca, 0x9c, 0x1a, 0x6b, 0xf4, 0xe5, 0x3b, 0x94, 0x20,
+	0x2e, 0xe1, 0x91, 0x86, 0x3b, 0x37, 0xe0, 0xb7, 0x27, 0xfb, 0x23, 0x78, 0xaf, 0xc6, 0x8b, 0x79,
+	0x6f, 0xd7, 0xb4, 0x1c, 0xd6, 0x78, 0x61, 0xb1, 0xe3, 0x7f, 0x1c, 0x18, 0x5f, 0x7f, 0xee, 0xd6,
+	0xfc, 0x7f, 0xd1, 0x33, 0xed, 0x29, 0xa6, 0x63, 0xd3, 0xb2, 0x3b, 0xf1, 0xef, 0xa6, 0x6d, 0xdf,
+	0x38, 0x70, 0x62, 0xea, 0x72, 0xbe, 0x55, 0x2c, 0xc9, 0xea, 0x18, 0x86, 0x8a, 0x48, 0x99, 0xa5,
+	0x27, 0xb3, 0xd4, 0x52, 0xdf, 0xa8, 0xee, 0xc6, 0xa4, 0x99, 0xc1, 0x51, 0xa6, 0xbe, 0x5f, 0x3e,
+	0x5f, 0xe0, 0x92, 0xb5, 0xfa, 0xb5, 0xc3, 0xb3, 0x28, 0xd1, 0xd3, 0x34, 0xb1, 0xd3, 0x34, 0xf9,
+	0xd9, 0x4e, 0xd3, 0xf4, 0x7d, 0x73, 0xe3, 0x6b, 0x75, 0x41, 0x8d, 0xb4, 0xb2, 0x54, 0x93, 0x6e,
+	0x94, 0xca, 0x63, 0xbc, 0x82, 0xd3, 0x3d, 0x01, 0xbe, 0xcb, 0x3f, 0x50, 0x43, 0x4a, 0x85, 0x37,
+	0x48, 0xb5, 0x10, 0xff, 0xed, 0xc0, 0xf8, 0x45, 0xc1, 0xc5, 0xac, 0xcb, 0x0b, 0xf1, 0xcd, 0x6b,
+	0xac, 0x05, 0xb7, 0x24, 0x10, 0x50, 0x5b, 0xc1, 0x72, 0x2e, 0xcf, 0x52, 0x27, 0x2e, 0x9b, 0x9e,
+	0x00, 0x79, 0x26, 0x09, 0xf8, 0xcb, 0x96, 0x55, 0xff, 0x23, 0x6d, 0xe5, 0x47, 0x3e, 0x01, 0x57,
+	0xb0, 0xd0, 0x7f, 0xab, 0xb7, 0x2b, 0x98, 0x0c, 0xba, 0x2c, 0xaa, 0x42, 0x98, 0x71, 0xaf, 0x85,
+	0xf8, 0x0f, 0x17, 0x60, 0x1d, 0x30, 0x39, 0x02, 0xb7, 0xc8, 0x55, 0x98, 0x7e, 0xea, 0x16, 0xf9,
+	0xce, 0x20, 0x1f, 0xc2, 0x80, 0x66, 0x82, 0xb5, 0x76, 0x0a, 0x28, 0x41, 0xb2, 0xc5, 0xbb, 0xc5,
+	0x6f, 0x98, 0x09, 0xb3, 0x66, 0xac, 0x48, 0x8e, 0xe1, 0xa0, 0x41, 0x6c, 0xe7, 0x45, 0xa3, 0x9e,
+	0x0e, 0xd2, 0xa1, 0x14, 0xbf, 0x6f, 0xe4, 0xb2, 0x93, 0x4c, 0xcc, 0xe9, 0x0a, 0x6b, 0x61, 0x56,
+	0x4e, 0x20, 0x35, 0x33, 0xa9, 0x90, 0x88, 0xac, 0x13, 0x19, 0xab, 0x50, 0xad, 0x9d, 0x20, 0xb5,
+	0xa2, 0xac, 0x58, 0x8b, 0x94, 0xb3, 0x3a, 0x1c, 0x69, 0x40, 0x2d, 0x91, 0xcf, 0x01, 0x6c, 0xff,
+	0x50, 0x11, 0x06, 0x6f, 0xa5, 0x25, 0x30, 0xde, 0x33, 0x11, 0x77, 0x70, 0xbc, 0x55, 0xbb, 0x5b,
+	0xf7, 0xc7, 0x14, 0x86, 0xa8, 0x30, 0xcc, 0x3f, 0xbd, 0xaf, 0xff, 0xe9, 0x1a, 0x3c, 0x35, 0xf6,
+	0xb3, 0xbf, 0x3c, 0xf0, 0xe5, 0xae, 0x21, 0x33, 0x80, 0xf5, 0xe6, 0x21, 0xc7, 0x7b, 0x16, 0x6c,
+	0x14, 0xee, 0x5b, 0x52, 0xf1, 0x3d, 0x09, 0xb1, 0x9e, 0xb9, 0x16, 0x62, 0x6b, 0xa7, 0x44, 0xe1,
+	0xb6, 0xa1, 0x87, 0x38, 0x87, 0x0f, 0xae, 0xb1, 0x40, 0x4e, 0xb4, 0xfb, 0xee, 0xc6, 0x8e, 0x4e,
+	0xf7, 0x58, 0x7b, 0xc4, 0x1f, 0xe0, 0xe8, 0xea, 0x78, 0x22, 0x8f, 0x77, 0x0f, 0x2d, 0x8d, 0x77,
+	0x72, 0xd3, 0x44, 0x8b, 0xef, 0x91, 0x05, 0x3c, 0xda, 0xf9, 0x99, 0x89, 0x19, 0x85, 0x37, 0x8d,
+	0xa2, 0xe8, 0xe3, 0x1b, 0x7d, 0xec, 0x1b, 0x8b, 0xa1, 0xea, 0x94, 0x4f, 0xff, 0x1b, 0x00, 0x0b,
+	0x18, 0x49, 0xac, 0xd5, 0x09, 0x00, 0x00,
 }
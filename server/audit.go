@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// auditContextInterceptor records where each request came from, and the
+// tenant it acts for, so the models layer can attach them to the audit events
+// it writes. The tenant is the one resolved by the tenant policy, or the one
+// named in the x-tenant-id header when callers are not authorized.
+func auditContextInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var ac models.AuditContext
+	if caller, ok := CallerFromContext(ctx); ok {
+		ac.Actor = caller.String()
+	}
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		ac.PeerIP = pr.Addr.String()
+		if host, _, err := net.SplitHostPort(ac.PeerIP); err == nil {
+			ac.PeerIP = host
+		}
+	}
+	tenant, resolved := tenantFromContext(ctx)
+	ac.Tenant = tenant
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if tenant := md["x-tenant-id"]; len(tenant) > 0 && !resolved {
+			ac.Tenant = tenant[0]
+		}
+		if userAgent := md["user-agent"]; len(userAgent) > 0 {
+			ac.UserAgent = userAgent[0]
+		}
+	}
+
+	return handler(models.WithAuditContext(ctx, ac), req)
+}
+
+// auditEventFilter converts a ListAuditEvents request into a query filter
+func auditEventFilter(in *pb.ListAuditEventsRequest) (models.AuditEventFilter, error) {
+	filter := models.AuditEventFilter{
+		Subject: in.User,
+		Type:    in.Type,
+		Limit:   int(in.Limit),
+	}
+
+	var err error
+	if in.From != nil {
+		if filter.From, err = ptypes.Timestamp(in.From); err != nil {
+			return filter, err
+		}
+	}
+	if in.To != nil {
+		if filter.To, err = ptypes.Timestamp(in.To); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+func auditEventProto(event models.AuditEvent) *pb.AuditEvent {
+	createdAt, _ := ptypes.TimestampProto(event.CreatedAt)
+	return &pb.AuditEvent{
+		Id:        uint64(event.ID),
+		Type:      event.Type,
+		Actor:     event.Actor,
+		Subject:   event.Subject,
+		PeerIp:    event.PeerIP,
+		UserAgent: event.UserAgent,
+		Outcome:   event.Outcome,
+		Reason:    event.Reason,
+		CreatedAt: createdAt,
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit", func() {
+	Describe("auditContextInterceptor", func() {
+		It("records the tenant, caller, peer IP and user agent", func() {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50123}})
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", "perScoreServer/1.0", "x-tenant-id", "acme"))
+			ctx = context.WithValue(ctx, callerKey{}, Caller{Name: "perScoreServer"})
+
+			var recorded models.AuditContext
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				recorded = models.AuditContextFrom(ctx)
+				return nil, nil
+			}
+			_, err := auditContextInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/GetSession"}, handler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(Equal(models.AuditContext{Tenant: "acme", Actor: "perScoreServer", PeerIP: "10.1.2.3", UserAgent: "perScoreServer/1.0"}))
+		})
+
+		It("prefers the tenant resolved for the caller to x-tenant-id", func() {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+			ctx = context.WithValue(ctx, tenantKey{}, "")
+
+			var recorded models.AuditContext
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				recorded = models.AuditContextFrom(ctx)
+				return nil, nil
+			}
+			_, err := auditContextInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/GetSession"}, handler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded.Tenant).To(BeEmpty())
+		})
+	})
+
+	Describe("auditEventFilter", func() {
+		It("converts the time range", func() {
+			from := time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC)
+			fromProto, _ := ptypes.TimestampProto(from)
+			filter, err := auditEventFilter(&pb.ListAuditEventsRequest{User: "jane@example.com", Type: models.AuditLoginFailure, From: fromProto, Limit: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filter.Subject).To(Equal("jane@example.com"))
+			Expect(filter.Type).To(Equal(models.AuditLoginFailure))
+			Expect(filter.From.Equal(from)).To(BeTrue())
+			Expect(filter.To.IsZero()).To(BeTrue())
+			Expect(filter.Limit).To(Equal(10))
+		})
+	})
+})
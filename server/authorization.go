@@ -0,0 +1,213 @@
+package server
+
+import (
+	"crypto/x509"
+	"path"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AnyCaller allows every caller presenting a verified client certificate
+const AnyCaller = "*"
+
+// DefaultAuthorization restricts account creation, the audit log and
+// requiring password changes to perScoreServer. RPCs that are not listed are
+// open to any caller.
+var DefaultAuthorization = map[string][]string{
+	"CreateUser":            {"perScoreServer"},
+	"ListAuditEvents":       {"perScoreServer"},
+	"RequirePasswordChange": {"perScoreServer"},
+}
+
+// AnyTenant lets a caller act for every tenant it names in x-tenant-id
+const AnyTenant = "*"
+
+// TenantBinding lists the tenants a caller, matched like the callers of
+// authorization rules, may act for
+type TenantBinding struct {
+	Caller  string   `mapstructure:"caller"`
+	Tenants []string `mapstructure:"tenants"`
+}
+
+// DefaultTenants lets perScoreServer act for any tenant. Other callers act
+// for the default tenant only.
+var DefaultTenants = []TenantBinding{{Caller: "perScoreServer", Tenants: []string{AnyTenant}}}
+
+type callerKey struct{}
+
+type tenantKey struct{}
+
+// Caller identifies the client that made a request
+type Caller struct {
+	// Name is the certificate subject common name
+	Name string
+	// URIs are the URI SANs of the certificate, such as SPIFFE IDs
+	URIs []string
+}
+
+// Is reports whether the caller is known by the given name or SAN
+func (c Caller) Is(identity string) bool {
+	if identity == AnyCaller || identity == c.Name {
+		return true
+	}
+	for _, uri := range c.URIs {
+		if uri == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the SPIFFE ID of the caller when it has one, and the common
+// name otherwise.
+func (c Caller) String() string {
+	if len(c.URIs) > 0 {
+		return c.URIs[0]
+	}
+	return c.Name
+}
+
+// CallerFromContext returns the caller authenticated for the request, if any
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+func callerFromCertificate(cert *x509.Certificate) Caller {
+	caller := Caller{Name: cert.Subject.CommonName}
+	for _, uri := range cert.URIs {
+		caller.URIs = append(caller.URIs, uri.String())
+	}
+	return caller
+}
+
+// callerPolicy maps lower-cased RPC names to the identities allowed to call
+// them. Names are case-insensitive because viper lower-cases config keys.
+type callerPolicy map[string][]string
+
+func newCallerPolicy(rules map[string][]string) callerPolicy {
+	if len(rules) == 0 {
+		rules = DefaultAuthorization
+	}
+	p := callerPolicy{}
+	for method, callers := range rules {
+		p[strings.ToLower(method)] = callers
+	}
+	return p
+}
+
+func (p callerPolicy) restricts(method string) bool {
+	_, restricted := p[strings.ToLower(method)]
+	return restricted
+}
+
+func (p callerPolicy) allows(method string, caller Caller) bool {
+	allowed, restricted := p[strings.ToLower(method)]
+	if !restricted {
+		return true
+	}
+	for _, identity := range allowed {
+		if caller.Is(identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor identifies the caller from its verified client
+// certificate and rejects calls it is not allowed to make.
+func (p callerPolicy) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var caller Caller
+	var verified bool
+	if pr, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := pr.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			caller = callerFromCertificate(tlsInfo.State.VerifiedChains[0][0])
+			verified = true
+		}
+	}
+
+	method := path.Base(info.FullMethod)
+	if p.restricts(method) && !verified {
+		return nil, status.Errorf(codes.Unauthenticated, "%s requires a verified client certificate", method)
+	}
+	if !p.allows(method, caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed to call %s", caller, method)
+	}
+
+	if verified {
+		ctx = context.WithValue(ctx, callerKey{}, caller)
+	}
+	return handler(ctx, req)
+}
+
+// tenantPolicy decides the tenant requests act for from their verified
+// caller, so callers cannot write to the audit chain or pick the password
+// policy of a tenant they do not belong to
+type tenantPolicy []TenantBinding
+
+func newTenantPolicy(bindings []TenantBinding) tenantPolicy {
+	if len(bindings) == 0 {
+		bindings = DefaultTenants
+	}
+	return tenantPolicy(bindings)
+}
+
+// tenant returns the tenant a request of caller naming requested in
+// x-tenant-id acts for. A caller bound to a single tenant acts for it without
+// naming it.
+func (p tenantPolicy) tenant(caller Caller, verified bool, requested string) (string, error) {
+	var allowed []string
+	if verified {
+		for _, binding := range p {
+			if caller.Is(binding.Caller) {
+				allowed = append(allowed, binding.Tenants...)
+			}
+		}
+	}
+
+	if requested == "" {
+		if len(allowed) == 1 && allowed[0] != AnyTenant {
+			return allowed[0], nil
+		}
+		return "", nil
+	}
+	for _, tenant := range allowed {
+		if tenant == requested || tenant == AnyTenant {
+			return requested, nil
+		}
+	}
+	if !verified {
+		return "", status.Errorf(codes.PermissionDenied, "acting for tenant %s requires a verified client certificate", requested)
+	}
+	return "", status.Errorf(codes.PermissionDenied, "%s is not allowed to act for tenant %s", caller, requested)
+}
+
+// UnaryServerInterceptor resolves the tenant of the request, rejecting
+// requests naming a tenant their caller does not belong to. It runs after
+// the caller policy identified the caller.
+func (p tenantPolicy) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var requested string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md["x-tenant-id"]) > 0 {
+		requested = md["x-tenant-id"][0]
+	}
+	caller, verified := CallerFromContext(ctx)
+	tenant, err := p.tenant(caller, verified, requested)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, tenantKey{}, tenant), req)
+}
+
+// tenantFromContext returns the tenant resolved by the tenant policy, if it
+// ran
+func tenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func contextWithClientCert(cert *x509.Certificate) context.Context {
+	state := tls.ConnectionState{}
+	if cert != nil {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+}
+
+var _ = Describe("Authorization", func() {
+	var policy callerPolicy
+	var handlerCalled bool
+	var handlerCaller Caller
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerCalled = true
+		handlerCaller, _ = CallerFromContext(ctx)
+		return "ok", nil
+	}
+	call := func(ctx context.Context, method string) error {
+		_, err := policy.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/" + method}, handler)
+		return err
+	}
+
+	BeforeEach(func() {
+		handlerCalled = false
+		handlerCaller = Caller{}
+		policy = newCallerPolicy(map[string][]string{
+			"createuser": {"perScoreServer", "spiffe://perscore.local/perScoreServer"},
+		})
+	})
+
+	It("allows a caller listed by common name", func() {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "perScoreServer"}}
+		Expect(call(contextWithClientCert(cert), "CreateUser")).To(Succeed())
+		Expect(handlerCalled).To(BeTrue())
+		Expect(handlerCaller.Name).To(Equal("perScoreServer"))
+	})
+
+	It("allows a caller listed by SPIFFE ID", func() {
+		id, _ := url.Parse("spiffe://perscore.local/perScoreServer")
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "pod-1234"}, URIs: []*url.URL{id}}
+		Expect(call(contextWithClientCert(cert), "CreateUser")).To(Succeed())
+		Expect(handlerCaller.String()).To(Equal("spiffe://perscore.local/perScoreServer"))
+	})
+
+	It("denies callers that are not listed", func() {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "perScoreReports"}}
+		err := call(contextWithClientCert(cert), "CreateUser")
+		Expect(grpc.Code(err)).To(Equal(codes.PermissionDenied))
+		Expect(handlerCalled).To(BeFalse())
+	})
+
+	It("requires a verified certificate for restricted RPCs", func() {
+		err := call(contextWithClientCert(nil), "CreateUser")
+		Expect(grpc.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(handlerCalled).To(BeFalse())
+	})
+
+	It("leaves RPCs without rules open", func() {
+		Expect(call(contextWithClientCert(nil), "GetSession")).To(Succeed())
+		Expect(handlerCalled).To(BeTrue())
+	})
+
+	It("falls back to the default rules", func() {
+		Expect(newCallerPolicy(nil).restricts("CreateUser")).To(BeTrue())
+	})
+
+	Describe("tenantPolicy", func() {
+		tenants := newTenantPolicy([]TenantBinding{
+			{Caller: "perScoreServer", Tenants: []string{AnyTenant}},
+			{Caller: "spiffe://perscore.local/acme-portal", Tenants: []string{"acme"}},
+		})
+		portal := Caller{Name: "portal", URIs: []string{"spiffe://perscore.local/acme-portal"}}
+
+		// resolve runs the interceptor for caller, nil when not verified,
+		// naming tenant in x-tenant-id
+		resolve := func(caller *Caller, tenant string) (string, error) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", tenant))
+			if caller != nil {
+				ctx = context.WithValue(ctx, callerKey{}, *caller)
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				tenant, _ := tenantFromContext(ctx)
+				return tenant, nil
+			}
+			resolved, err := tenants.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/CreateUser"}, handler)
+			tenant, _ = resolved.(string)
+			return tenant, err
+		}
+
+		It("lets callers act for the tenants they are listed for", func() {
+			Expect(resolve(&Caller{Name: "perScoreServer"}, "globex")).To(Equal("globex"))
+			Expect(resolve(&portal, "acme")).To(Equal("acme"))
+		})
+
+		It("uses the only tenant of a caller that names none", func() {
+			Expect(resolve(&portal, "")).To(Equal("acme"))
+			Expect(resolve(&Caller{Name: "perScoreServer"}, "")).To(BeEmpty())
+		})
+
+		It("refuses tenants the caller does not belong to", func() {
+			_, err := resolve(&portal, "globex")
+			Expect(grpc.Code(err)).To(Equal(codes.PermissionDenied))
+			_, err = resolve(&Caller{Name: "mallory"}, "acme")
+			Expect(grpc.Code(err)).To(Equal(codes.PermissionDenied))
+			_, err = resolve(nil, "acme")
+			Expect(grpc.Code(err)).To(Equal(codes.PermissionDenied))
+			Expect(resolve(nil, "")).To(BeEmpty())
+		})
+
+		It("falls back to the default bindings", func() {
+			Expect(newTenantPolicy(nil)).To(Equal(tenantPolicy(DefaultTenants)))
+		})
+	})
+})
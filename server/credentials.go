@@ -0,0 +1,158 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// certReloader serves the TLS certificate and client CA bundle from disk and
+// reloads them whenever the files change, so rotated certificates are picked
+// up without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		done:     make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// Watch the directories rather than the files themselves: secrets mounted
+	// by an orchestrator are usually swapped through a symlink, which would
+	// silently drop a watch on the old file.
+	dirs := map[string]bool{}
+	for _, file := range []string{certFile, keyFile, caFile} {
+		if file != "" {
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+	r.watcher = watcher
+	go r.watch()
+
+	return r, nil
+}
+
+// reload reads the certificate, key and CA bundle from disk. On failure the
+// previously loaded credentials are kept.
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair: %v", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA bundle: %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client CA bundle contains no certificates")
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) watch() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Errorf("Error reloading TLS credentials: %+v", err)
+			} else {
+				log.Infof("Reloaded TLS credentials after change to %s", event.Name)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("Error watching TLS credentials: %+v", err)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Close stops watching the credential files
+func (r *certReloader) Close() error {
+	close(r.done)
+	return r.watcher.Close()
+}
+
+// TLSConfig returns a server configuration that always uses the most recently
+// loaded credentials. Client certificates are verified when a CA bundle is
+// configured, and required as well when requireClientCert is set. nextProtos
+// are the ALPN protocols offered, h2 for gRPC when none are given.
+func (r *certReloader) TLSConfig(requireClientCert bool, nextProtos ...string) *tls.Config {
+	if len(nextProtos) == 0 {
+		nextProtos = []string{"h2"}
+	}
+
+	clientAuth := tls.NoClientCert
+	if r.caFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    r.clientCAs,
+				NextProtos:   nextProtos,
+			}, nil
+		},
+	}
+}
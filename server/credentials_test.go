@@ -0,0 +1,90 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and its key to dir
+func writeSelfSignedCert(dir, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	Expect(ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0600)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, "ca.crt"), certPEM, 0600)).To(Succeed())
+}
+
+var _ = Describe("certReloader", func() {
+	var dir string
+	var reloader *certReloader
+
+	servedCommonName := func() string {
+		config, err := reloader.TLSConfig(true).GetConfigForClient(&tls.ClientHelloInfo{})
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+		Expect(err).NotTo(HaveOccurred())
+		return cert.Subject.CommonName
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "perscoreauth-tls")
+		Expect(err).NotTo(HaveOccurred())
+		writeSelfSignedCert(dir, "first")
+		reloader, err = newCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		reloader.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("verifies client certificates against the CA bundle", func() {
+		config, err := reloader.TLSConfig(true).GetConfigForClient(&tls.ClientHelloInfo{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.ClientAuth).To(Equal(tls.RequireAndVerifyClientCert))
+		Expect(config.ClientCAs).NotTo(BeNil())
+	})
+
+	It("picks up rotated certificates", func() {
+		Expect(servedCommonName()).To(Equal("first"))
+		writeSelfSignedCert(dir, "second")
+		Eventually(servedCommonName, 5*time.Second).Should(Equal("second"))
+	})
+
+	It("fails when the key pair is missing", func() {
+		_, err := newCertReloader(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "tls.key"), "")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGatewayAddress is the address of the HTTP/JSON gateway
+const DefaultGatewayAddress = "localhost:8080"
+
+// maxGatewayBodySize bounds the size of request bodies
+const maxGatewayBodySize = 1 << 20
+
+// gatewayRoute maps an HTTP method and path to an RPC of the User service
+type gatewayRoute struct {
+	httpMethod string
+	path       string
+	rpc        string
+	newRequest func() proto.Message
+	call       func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error)
+}
+
+// gatewayRoutes lists the RPCs reachable over HTTP. POST requests carry the
+// request message as JSON in the body, GET requests as query parameters.
+var gatewayRoutes = []gatewayRoute{
+	{
+		httpMethod: http.MethodPost,
+		path:       "/v1/users",
+		rpc:        "CreateUser",
+		newRequest: func() proto.Message { return &pb.CreateUserRequest{} },
+		call: func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error) {
+			return srv.CreateUser(ctx, req.(*pb.CreateUserRequest))
+		},
+	},
+	{
+		httpMethod: http.MethodPost,
+		path:       "/v1/sessions",
+		rpc:        "GetSession",
+		newRequest: func() proto.Message { return &pb.GetSessionRequest{} },
+		call: func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error) {
+			return srv.GetSession(ctx, req.(*pb.GetSessionRequest))
+		},
+	},
+	{
+		httpMethod: http.MethodPost,
+		path:       "/v1/password",
+		rpc:        "ChangePassword",
+		newRequest: func() proto.Message { return &pb.ChangePasswordRequest{} },
+		call: func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error) {
+			return srv.ChangePassword(ctx, req.(*pb.ChangePasswordRequest))
+		},
+	},
+	{
+		httpMethod: http.MethodPost,
+		path:       "/v1/password-change-requirements",
+		rpc:        "RequirePasswordChange",
+		newRequest: func() proto.Message { return &pb.RequirePasswordChangeRequest{} },
+		call: func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error) {
+			return srv.RequirePasswordChange(ctx, req.(*pb.RequirePasswordChangeRequest))
+		},
+	},
+	{
+		httpMethod: http.MethodGet,
+		path:       "/v1/audit-events",
+		rpc:        "ListAuditEvents",
+		newRequest: func() proto.Message { return &pb.ListAuditEventsRequest{} },
+		call: func(ctx context.Context, srv pb.UserServer, req interface{}) (interface{}, error) {
+			return srv.ListAuditEvents(ctx, req.(*pb.ListAuditEventsRequest))
+		},
+	},
+}
+
+// gateway serves the User service as HTTP/JSON using the protobuf JSON
+// mapping. Requests go through the same interceptors as gRPC requests, so
+// they are logged, measured, authorized and audited the same way.
+type gateway struct {
+	server      pb.UserServer
+	interceptor grpc.UnaryServerInterceptor
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func newGateway(server pb.UserServer, interceptor grpc.UnaryServerInterceptor) *gateway {
+	return &gateway{
+		server:      server,
+		interceptor: interceptor,
+		marshaler:   &jsonpb.Marshaler{OrigName: true},
+		unmarshaler: &jsonpb.Unmarshaler{},
+	}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var route *gatewayRoute
+	for i := range gatewayRoutes {
+		if gatewayRoutes[i].path == r.URL.Path {
+			route = &gatewayRoutes[i]
+			break
+		}
+	}
+	if route == nil {
+		g.writeError(w, status.Error(codes.NotFound, "no such endpoint"))
+		return
+	}
+	if r.Method != route.httpMethod {
+		w.Header().Set("Allow", route.httpMethod)
+		g.writeJSON(w, http.StatusMethodNotAllowed, errorBody(codes.Unimplemented, "method not allowed"))
+		return
+	}
+
+	req := route.newRequest()
+	if err := g.decodeRequest(r, req); err != nil {
+		g.writeError(w, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+
+	requestID := r.Header.Get(RequestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	w.Header().Set(RequestIDHeader, requestID)
+
+	ctx := metadata.NewIncomingContext(r.Context(), gatewayMetadata(r.Header, requestID))
+	ctx = peer.NewContext(ctx, gatewayPeer(r))
+
+	info := &grpc.UnaryServerInfo{Server: g.server, FullMethod: "/user.User/" + route.rpc}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return route.call(ctx, g.server, req)
+	}
+	resp, err := g.interceptor(ctx, req, info, handler)
+	if err != nil {
+		g.writeError(w, err)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := g.marshaler.Marshal(&body, resp.(proto.Message)); err != nil {
+		log.Errorf("Error encoding %s response: %+v", route.rpc, err)
+		g.writeError(w, status.Error(codes.Internal, "encoding the response failed"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+func (g *gateway) decodeRequest(r *http.Request, req proto.Message) error {
+	if r.Method == http.MethodGet {
+		body, err := queryToJSON(r.URL.Query(), req)
+		if err != nil {
+			return err
+		}
+		return g.unmarshaler.Unmarshal(bytes.NewReader(body), req)
+	}
+
+	err := g.unmarshaler.Unmarshal(io.LimitReader(r.Body, maxGatewayBodySize), req)
+	if err == io.EOF {
+		// An empty body is an empty message
+		return nil
+	}
+	return err
+}
+
+// queryToJSON converts query parameters into a JSON object for req. Numbers
+// and booleans are left unquoted so jsonpb accepts them.
+func queryToJSON(query url.Values, req proto.Message) ([]byte, error) {
+	kinds := map[string]reflect.Kind{}
+	t := reflect.TypeOf(req).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := protoFieldName(field); name != "" {
+			kinds[name] = field.Type.Kind()
+		}
+	}
+
+	object := map[string]json.RawMessage{}
+	for name, values := range query {
+		value := values[len(values)-1]
+		switch kinds[name] {
+		case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
+			object[name] = json.RawMessage(value)
+		default:
+			quoted, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = quoted
+		}
+	}
+	return json.Marshal(object)
+}
+
+// gatewayMetadata passes on the headers the interceptors and handlers read
+func gatewayMetadata(header http.Header, requestID string) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-") || name == "user-agent" || name == "authorization" || name == "accept-language" || name == IdempotencyKeyHeader {
+			md[name] = values
+		}
+	}
+	md[RequestIDHeader] = []string{requestID}
+	return md
+}
+
+// gatewayPeer describes the HTTP client like gRPC describes its peers, so
+// client certificates authorize gateway requests the same way
+func gatewayPeer(r *http.Request) *peer.Peer {
+	p := &peer.Peer{}
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return p
+}
+
+func (g *gateway) writeError(w http.ResponseWriter, err error) {
+	code := grpc.Code(err)
+	body := errorBody(code, grpc.ErrorDesc(err))
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Proto().Details {
+			encoded, err := g.marshaler.MarshalToString(detail)
+			if err != nil {
+				log.Errorf("Error encoding %s error details: %+v", detail.TypeUrl, err)
+				continue
+			}
+			body.Details = append(body.Details, json.RawMessage(encoded))
+		}
+	}
+	g.writeJSON(w, HTTPStatusFromCode(code), body)
+}
+
+func (g *gateway) writeJSON(w http.ResponseWriter, httpStatus int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(body)
+}
+
+// gatewayError is the body of failed gateway requests. Details are the
+// details of the gRPC status, with their type under "@type".
+type gatewayError struct {
+	Code    int               `json:"code"`
+	Status  string            `json:"status"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+func errorBody(code codes.Code, message string) gatewayError {
+	return gatewayError{Code: int(code), Status: code.String(), Message: message}
+}
+
+// HTTPStatusFromCode returns the HTTP status for a gRPC status code
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/ptypes"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// stubUserServer records the requests it receives
+type stubUserServer struct {
+	ctx      context.Context
+	request  interface{}
+	response interface{}
+	err      error
+}
+
+func (s *stubUserServer) CreateUser(ctx context.Context, in *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	s.ctx, s.request = ctx, in
+	response, _ := s.response.(*pb.CreateUserResponse)
+	return response, s.err
+}
+
+func (s *stubUserServer) GetSession(ctx context.Context, in *pb.GetSessionRequest) (*pb.GetSessionResponse, error) {
+	s.ctx, s.request = ctx, in
+	response, _ := s.response.(*pb.GetSessionResponse)
+	return response, s.err
+}
+
+func (s *stubUserServer) ListAuditEvents(ctx context.Context, in *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	s.ctx, s.request = ctx, in
+	response, _ := s.response.(*pb.ListAuditEventsResponse)
+	return response, s.err
+}
+
+func (s *stubUserServer) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	s.ctx, s.request = ctx, in
+	response, _ := s.response.(*pb.ChangePasswordResponse)
+	return response, s.err
+}
+
+func (s *stubUserServer) RequirePasswordChange(ctx context.Context, in *pb.RequirePasswordChangeRequest) (*pb.RequirePasswordChangeResponse, error) {
+	s.ctx, s.request = ctx, in
+	response, _ := s.response.(*pb.RequirePasswordChangeResponse)
+	return response, s.err
+}
+
+var _ = Describe("Gateway", func() {
+	var (
+		stub   *stubUserServer
+		method string
+	)
+
+	BeforeEach(func() {
+		stub = &stubUserServer{}
+		method = ""
+	})
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			method = info.FullMethod
+			return handler(ctx, req)
+		}
+		recorder := httptest.NewRecorder()
+		newGateway(stub, interceptor).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	It("maps POST /v1/users to CreateUser", func() {
+		stub.response = &pb.CreateUserResponse{Status: "SUCCESS", Message: "User created successfully", Token: "abc"}
+		req := httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"first_name":"Ada","lastName":"Lovelace","email":"ada@example.com","age":36,"location":{"city":"London"}}`))
+		req.Header.Set("X-Tenant-Id", "acme")
+		recorder := serve(req)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(method).To(Equal("/user.User/CreateUser"))
+		Expect(stub.request).To(Equal(&pb.CreateUserRequest{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     "ada@example.com",
+			Age:       36,
+			Location:  &pb.CreateUserRequest_Location{City: "London"},
+		}))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"status":"SUCCESS","message":"User created successfully","token":"abc"}`))
+
+		md, _ := metadata.FromIncomingContext(stub.ctx)
+		Expect(md["x-tenant-id"]).To(Equal([]string{"acme"}))
+		Expect(md[RequestIDHeader]).To(Equal([]string{recorder.Header().Get(RequestIDHeader)}))
+	})
+
+	It("maps POST /v1/sessions to GetSession", func() {
+		stub.response = &pb.GetSessionResponse{Status: "SUCCESS"}
+		recorder := serve(httptest.NewRequest("POST", "/v1/sessions", strings.NewReader(`{"email":"ada@example.com","password":"secret"}`)))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(stub.request).To(Equal(&pb.GetSessionRequest{Email: "ada@example.com", Password: "secret"}))
+	})
+
+	It("reads GET requests from the query string", func() {
+		stub.response = &pb.ListAuditEventsResponse{Status: "SUCCESS"}
+		recorder := serve(httptest.NewRequest("GET", "/v1/audit-events?user=ada@example.com&limit=5&from=2017-10-01T00:00:00Z", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		from, _ := ptypes.TimestampProto(time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC))
+		Expect(stub.request).To(Equal(&pb.ListAuditEventsRequest{User: "ada@example.com", Limit: 5, From: from}))
+	})
+
+	It("translates gRPC status codes", func() {
+		stub.err = status.Error(codes.PermissionDenied, "caller is not allowed")
+		recorder := serve(httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{}`)))
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		var body gatewayError
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		Expect(body).To(Equal(gatewayError{Code: int(codes.PermissionDenied), Status: "PermissionDenied", Message: "caller is not allowed"}))
+	})
+
+	It("passes on the details of errors", func() {
+		stub.err = alreadyExists(&pb.CreateUserResponse{
+			Status:  "FAILURE",
+			Message: "Signup failed. Please try again.",
+			Fields:  []*pb.CreateUserResponse_Field{{Name: "email", Validation: "Taken", Message: "Email is already taken"}},
+		})
+		recorder := serve(httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{}`)))
+
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+			"code": 6,
+			"status": "AlreadyExists",
+			"message": "Email is already taken",
+			"details": [{
+				"@type": "type.googleapis.com/user.CreateUserResponse",
+				"status": "FAILURE",
+				"message": "Signup failed. Please try again.",
+				"fields": [{"name": "email", "validation": "Taken", "message": "Email is already taken"}]
+			}]
+		}`))
+	})
+
+	It("rejects malformed requests", func() {
+		Expect(serve(httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"age":"old"}`))).Code).To(Equal(http.StatusBadRequest))
+		Expect(serve(httptest.NewRequest("POST", "/v1/users", strings.NewReader(`{"unknown":1}`))).Code).To(Equal(http.StatusBadRequest))
+		Expect(stub.request).To(BeNil())
+	})
+
+	It("rejects unknown endpoints and methods", func() {
+		Expect(serve(httptest.NewRequest("GET", "/v1/unknown", nil)).Code).To(Equal(http.StatusNotFound))
+
+		recorder := serve(httptest.NewRequest("GET", "/v1/users", nil))
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(recorder.Header().Get("Allow")).To(Equal("POST"))
+	})
+
+	It("maps every code to an HTTP status", func() {
+		Expect(HTTPStatusFromCode(codes.OK)).To(Equal(http.StatusOK))
+		Expect(HTTPStatusFromCode(codes.InvalidArgument)).To(Equal(http.StatusBadRequest))
+		Expect(HTTPStatusFromCode(codes.Unauthenticated)).To(Equal(http.StatusUnauthorized))
+		Expect(HTTPStatusFromCode(codes.AlreadyExists)).To(Equal(http.StatusConflict))
+		Expect(HTTPStatusFromCode(codes.Unavailable)).To(Equal(http.StatusServiceUnavailable))
+		Expect(HTTPStatusFromCode(codes.Unknown)).To(Equal(http.StatusInternalServerError))
+	})
+})
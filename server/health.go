@@ -0,0 +1,158 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"perScoreAuth/metrics"
+	"perScoreAuth/models"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultHealthCheckInterval is how often the database is probed
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// userServiceName is the name health checks use for the User service
+const userServiceName = "user.User"
+
+// healthServer implements grpc.health.v1. The server as a whole ("") and the
+// User service share one status since both depend on the database.
+type healthServer struct {
+	mu      sync.RWMutex
+	serving bool
+}
+
+// Check implements healthpb.HealthServer
+func (h *healthServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if in.Service != "" && in.Service != userServiceName {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	if h.Serving() {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+}
+
+// Serving reports whether requests can currently be handled
+func (h *healthServer) Serving() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.serving
+}
+
+// SetServing updates the status and logs changes
+func (h *healthServer) SetServing(serving bool, reason string) {
+	h.mu.Lock()
+	changed := h.serving != serving
+	h.serving = serving
+	h.mu.Unlock()
+
+	if changed && serving {
+		log.Info("perScoreAuth is SERVING")
+	} else if changed {
+		log.Warnf("perScoreAuth is NOT_SERVING: %s", reason)
+	}
+}
+
+// ServeLive answers /healthz: the process is up and handling requests
+func (h *healthServer) ServeLive(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// ServeReady answers /readyz with the same status as the gRPC health service
+func (h *healthServer) ServeReady(w http.ResponseWriter, r *http.Request) {
+	if !h.Serving() {
+		http.Error(w, "not serving", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// databaseMonitor connects the server to the database and keeps probing it,
+// flipping the health status when the database becomes unreachable or the
+// schema is not up to date.
+type databaseMonitor struct {
+	env      string
+	interval time.Duration
+	server   *Server
+	health   *healthServer
+	// db is the database behind the server's store once connected
+	db      *gorm.DB
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newDatabaseMonitor starts probing the database in the background
+func newDatabaseMonitor(env string, interval time.Duration, server *Server, health *healthServer) *databaseMonitor {
+	m := &databaseMonitor{
+		env:      env,
+		interval: interval,
+		server:   server,
+		health:   health,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+func (m *databaseMonitor) run() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.check()
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *databaseMonitor) check() {
+	db := m.db
+	if db == nil {
+		var err error
+		if db, err = models.OpenDatabase(m.env); err != nil {
+			m.health.SetServing(false, "cannot connect to the database: "+err.Error())
+			return
+		}
+		if err := metrics.RegisterDBStats(db.DB()); err != nil {
+			log.Errorf("Error registering database metrics: %+v", err)
+		}
+		m.db = db
+		m.server.setStore(models.NewGormStore(db))
+	}
+
+	if err := models.PingDatabase(db, m.interval); err != nil {
+		m.health.SetServing(false, "database is unreachable: "+err.Error())
+		return
+	}
+	current, err := models.SchemaCurrent(db)
+	if err != nil {
+		m.health.SetServing(false, "cannot read the schema version: "+err.Error())
+		return
+	}
+	if !current {
+		m.health.SetServing(false, "database schema is not up to date, run migrate up")
+		return
+	}
+	m.health.SetServing(true, "")
+}
+
+// stop waits for a running check to finish, so the database is no longer
+// used once stop returns
+func (m *databaseMonitor) stop() {
+	close(m.done)
+	<-m.stopped
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var health *healthServer
+
+	BeforeEach(func() {
+		health = &healthServer{}
+	})
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		Expect(err).NotTo(HaveOccurred())
+		return resp.Status
+	}
+
+	ready := func() int {
+		recorder := httptest.NewRecorder()
+		health.ServeReady(recorder, httptest.NewRequest("GET", "/readyz", nil))
+		return recorder.Code
+	}
+
+	It("is NOT_SERVING until the database is ready", func() {
+		Expect(check("")).To(Equal(healthpb.HealthCheckResponse_NOT_SERVING))
+		Expect(check(userServiceName)).To(Equal(healthpb.HealthCheckResponse_NOT_SERVING))
+		Expect(ready()).To(Equal(http.StatusServiceUnavailable))
+
+		health.SetServing(true, "")
+		Expect(check("")).To(Equal(healthpb.HealthCheckResponse_SERVING))
+		Expect(check(userServiceName)).To(Equal(healthpb.HealthCheckResponse_SERVING))
+		Expect(ready()).To(Equal(http.StatusOK))
+
+		health.SetServing(false, "database is unreachable")
+		Expect(check("")).To(Equal(healthpb.HealthCheckResponse_NOT_SERVING))
+		Expect(ready()).To(Equal(http.StatusServiceUnavailable))
+	})
+
+	It("rejects unknown services", func() {
+		_, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "user.Unknown"})
+		Expect(grpc.Code(err)).To(Equal(codes.NotFound))
+	})
+
+	It("is always live", func() {
+		recorder := httptest.NewRecorder()
+		health.ServeLive(recorder, httptest.NewRequest("GET", "/healthz", nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	It("stays NOT_SERVING when the database cannot be opened", func() {
+		server := &Server{}
+		health.SetServing(true, "")
+		monitor := &databaseMonitor{env: "health_test_unconfigured", interval: DefaultHealthCheckInterval, server: server, health: health}
+		monitor.check()
+		Expect(health.Serving()).To(BeFalse())
+		Expect(server.store()).To(BeNil())
+	})
+
+	It("answers requests with Unavailable without a database", func() {
+		_, err := (&Server{}).CreateUser(context.Background(), &pb.CreateUserRequest{})
+		Expect(grpc.Code(err)).To(Equal(codes.Unavailable))
+	})
+})
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// IdempotencyKeyHeader is the metadata key, and HTTP header, under which
+// clients send the key making retries of a request safe
+const IdempotencyKeyHeader = "idempotency-key"
+
+// DefaultIdempotencyWindow is how long responses are replayed when no window
+// is configured
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// DefaultIdempotencyLease is how long a request holds its idempotency key
+// before a retry may take it over, when no lease is configured. It should
+// exceed the time requests take, or a retry runs a request still in progress
+// again.
+const DefaultIdempotencyLease = time.Minute
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// idempotentMethods are the mutating RPCs whose responses are replayed for
+// requests repeating an idempotency key
+var idempotentMethods = map[string]bool{
+	"/user.User/CreateUser":            true,
+	"/user.User/ChangePassword":        true,
+	"/user.User/RequirePasswordChange": true,
+}
+
+// idempotencyInterceptor runs a request made with an idempotency key once
+// per tenant. The successful response is stored for the idempotency window
+// and returned again for requests repeating the key, which must be identical.
+// A failed request releases its key so it can be retried, and the key of a
+// request that never finished, because the process stopped, is taken over by
+// a retry once the lease of the request ends.
+func (s *Server) idempotencyInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var key string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[IdempotencyKeyHeader]) > 0 {
+		key = md[IdempotencyKeyHeader][0]
+	}
+	store := s.store()
+	if key == "" || !idempotentMethods[info.FullMethod] || store == nil {
+		return handler(ctx, req)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, status.Errorf(codes.InvalidArgument, "idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+	}
+
+	fingerprint, err := requestFingerprint(info.FullMethod, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "encoding the request failed")
+	}
+	window, lease := s.IdempotencyWindow, s.IdempotencyLease
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	if lease <= 0 {
+		lease = DefaultIdempotencyLease
+	}
+	now := time.Now().UTC()
+	record := models.IdempotencyRecord{
+		Tenant:      models.AuditContextFrom(ctx).Tenant,
+		Key:         key,
+		Method:      info.FullMethod,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(lease),
+		ExpiresAt:   now.Add(window),
+	}
+	logger := log.WithFields(log.Fields{"method": info.FullMethod, "idempotency_key": key})
+
+	existing, found, err := store.ReserveIdempotencyKey(&record)
+	switch {
+	case err != nil:
+		logger.WithField("error", err).Error("Reserving idempotency key failed")
+		return nil, status.Error(codes.Unavailable, "idempotency keys are not available")
+	case found && existing.Fingerprint != fingerprint:
+		return nil, status.Error(codes.Aborted, "idempotency key was used for a different request")
+	case found && existing.Response == nil:
+		return nil, status.Error(codes.Aborted, "a request with this idempotency key is in progress")
+	case found:
+		resp, err := decodeResponse(existing.Response)
+		if err != nil {
+			logger.WithField("error", err).Error("Decoding stored response failed")
+			return nil, status.Error(codes.Internal, "decoding the stored response failed")
+		}
+		logger.Info("Replayed response for idempotency key")
+		return resp, nil
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		if err := store.ReleaseIdempotencyKey(record.Tenant, key); err != nil {
+			logger.WithField("error", err).Error("Releasing idempotency key failed")
+		}
+		return resp, err
+	}
+	encoded, err := encodeResponse(resp)
+	if err == nil {
+		err = store.CompleteIdempotencyKey(record.Tenant, key, encoded)
+	}
+	if err != nil {
+		logger.WithField("error", err).Error("Storing response for idempotency key failed")
+	}
+	return resp, nil
+}
+
+// requestFingerprint identifies the request req to method. Passwords are
+// left out: the fingerprint is stored, and a fast hash of them could be
+// guessed offline.
+func requestFingerprint(method string, req interface{}) (string, error) {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return "", status.Error(codes.Internal, "request is not a protocol buffer")
+	}
+	encoded, err := proto.Marshal(withoutPasswords(message))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(method+"\n"), encoded...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withoutPasswords returns a copy of req with its passwords cleared
+func withoutPasswords(req proto.Message) proto.Message {
+	switch r := proto.Clone(req).(type) {
+	case *pb.CreateUserRequest:
+		r.Password = ""
+		return r
+	case *pb.ChangePasswordRequest:
+		r.Password, r.NewPassword = "", ""
+		return r
+	}
+	return req
+}
+
+// encodeResponse encodes resp with its type, for decodeResponse
+func encodeResponse(resp interface{}) ([]byte, error) {
+	message, ok := resp.(proto.Message)
+	if !ok {
+		return nil, status.Error(codes.Internal, "response is not a protocol buffer")
+	}
+	wrapped, err := ptypes.MarshalAny(message)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(wrapped)
+}
+
+func decodeResponse(encoded []byte) (proto.Message, error) {
+	var wrapped any.Any
+	if err := proto.Unmarshal(encoded, &wrapped); err != nil {
+		return nil, err
+	}
+	var resp ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(&wrapped, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Message, nil
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Idempotency", func() {
+	var (
+		store  *models.MemoryStore
+		server *Server
+		calls  int
+		// failure is the error of the handler
+		failure error
+		info    = &grpc.UnaryServerInfo{FullMethod: "/user.User/CreateUser"}
+	)
+
+	BeforeEach(func() {
+		store = models.NewMemoryStore()
+		server = &Server{Store: store}
+		calls, failure = 0, nil
+	})
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		if failure != nil {
+			return nil, failure
+		}
+		return &pb.CreateUserResponse{Status: "SUCCESS", Message: "You have signed up successfully!"}, nil
+	}
+	call := func(key string, req *pb.CreateUserRequest) (interface{}, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, key))
+		return server.idempotencyInterceptor(ctx, req, info, handler)
+	}
+	// reserve holds key for req as a request that has not finished would
+	reserve := func(key string, req *pb.CreateUserRequest, lockedUntil time.Time) {
+		fingerprint, err := requestFingerprint(info.FullMethod, req)
+		Expect(err).NotTo(HaveOccurred())
+		_, found, err := store.ReserveIdempotencyKey(&models.IdempotencyRecord{
+			Key:         key,
+			Method:      info.FullMethod,
+			Fingerprint: fingerprint,
+			LockedUntil: lockedUntil,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeFalse())
+	}
+
+	It("replays the response of a finished request", func() {
+		req := &pb.CreateUserRequest{Email: "ada@example.com"}
+		first, err := call("signup-1", req)
+		Expect(err).NotTo(HaveOccurred())
+		retry, err := call("signup-1", req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(retry).To(Equal(first))
+		Expect(calls).To(Equal(1))
+
+		_, err = call("signup-1", &pb.CreateUserRequest{Email: "bob@example.com"})
+		Expect(grpc.Code(err)).To(Equal(codes.Aborted))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("refuses retries while the request holding the key runs", func() {
+		req := &pb.CreateUserRequest{Email: "ada@example.com"}
+		reserve("signup-1", req, time.Now().Add(time.Minute))
+		_, err := call("signup-1", req)
+		Expect(grpc.Code(err)).To(Equal(codes.Aborted))
+		Expect(err.Error()).To(ContainSubstring("in progress"))
+		Expect(calls).To(BeZero())
+	})
+
+	It("runs a retry once the lease of an unfinished request ended", func() {
+		req := &pb.CreateUserRequest{Email: "ada@example.com"}
+		reserve("signup-1", req, time.Now().Add(-time.Second))
+		_, err := call("signup-1", req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(1))
+
+		_, err = call("signup-1", req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("leaves passwords out of request fingerprints", func() {
+		fingerprint := func(req interface{}) string {
+			fp, err := requestFingerprint(info.FullMethod, req)
+			Expect(err).NotTo(HaveOccurred())
+			return fp
+		}
+		req := &pb.CreateUserRequest{Email: "ada@example.com", Password: "s3cret-Passw0rd"}
+		Expect(fingerprint(req)).To(Equal(fingerprint(&pb.CreateUserRequest{Email: "ada@example.com"})))
+		Expect(req.Password).To(Equal("s3cret-Passw0rd"))
+		Expect(fingerprint(req)).NotTo(Equal(fingerprint(&pb.CreateUserRequest{Email: "bob@example.com"})))
+		Expect(fingerprint(&pb.ChangePasswordRequest{Email: "ada@example.com", Password: "old", NewPassword: "new"})).
+			To(Equal(fingerprint(&pb.ChangePasswordRequest{Email: "ada@example.com"})))
+	})
+
+	It("releases the key of a request that failed", func() {
+		req := &pb.CreateUserRequest{Email: "ada@example.com"}
+		failure = errors.New("database is down")
+		_, err := call("signup-1", req)
+		Expect(err).To(Equal(failure))
+
+		failure = nil
+		_, err = call("signup-1", req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(2))
+	})
+
+	It("releases the key of a signup the store failed", func() {
+		models.PasswordCost = bcrypt.MinCost
+		defer func() { models.PasswordCost = bcrypt.DefaultCost }()
+		failures := 1
+		server.Store = &failingUserStore{MemoryStore: store, failures: &failures}
+		req := &pb.CreateUserRequest{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     "ada@example.com",
+			Password:  "s3cret-Passw0rd",
+			Age:       36,
+			Role:      "admin",
+			Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
+		}
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "signup-1"))
+		createUser := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return server.CreateUser(ctx, req.(*pb.CreateUserRequest))
+		}
+
+		_, err := server.idempotencyInterceptor(ctx, req, info, createUser)
+		Expect(grpc.Code(err)).To(Equal(codes.Unavailable))
+
+		resp, err := server.idempotencyInterceptor(ctx, req, info, createUser)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.(*pb.CreateUserResponse).Status).To(Equal("SUCCESS"))
+		_, err = store.FindUserByEmail("ada@example.com")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("replays responses through the gateway", func() {
+		stub := &stubUserServer{response: &pb.CreateUserResponse{Status: "SUCCESS", Message: "You have signed up successfully!"}}
+		gateway := newGateway(stub, server.idempotencyInterceptor)
+		post := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/v1/users", strings.NewReader(body))
+			req.Header.Set("Idempotency-Key", "signup-1")
+			recorder := httptest.NewRecorder()
+			gateway.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		first := post(`{"email":"ada@example.com"}`)
+		Expect(first.Code).To(Equal(http.StatusOK))
+		Expect(stub.request).NotTo(BeNil())
+
+		stub.request = nil
+		retry := post(`{"email":"ada@example.com"}`)
+		Expect(retry.Code).To(Equal(http.StatusOK))
+		Expect(retry.Body.String()).To(MatchJSON(first.Body.String()))
+		Expect(stub.request).To(BeNil())
+
+		Expect(post(`{"email":"bob@example.com"}`).Code).To(Equal(http.StatusConflict))
+		Expect(stub.request).To(BeNil())
+	})
+})
+
+// failingUserStore fails to create users while failures is positive,
+// counting it down
+type failingUserStore struct {
+	*models.MemoryStore
+	failures *int
+}
+
+func (s *failingUserStore) InTransaction(fn func(tx models.Store) error) error {
+	return s.MemoryStore.InTransaction(func(tx models.Store) error {
+		return fn(&failingUserStore{MemoryStore: tx.(*models.MemoryStore), failures: s.failures})
+	})
+}
+
+func (s *failingUserStore) CreateUser(user *models.User) error {
+	if *s.failures > 0 {
+		*s.failures--
+		return errors.New("database is down")
+	}
+	return s.MemoryStore.CreateUser(user)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"perScoreAuth/models"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// localeInterceptor asks the models layer for messages in the languages of
+// the accept-language header, which the gateway passes on from HTTP requests
+func localeInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if locales := acceptedLocales(strings.Join(md["accept-language"], ",")); len(locales) > 0 {
+			ctx = models.WithLocales(ctx, locales...)
+		}
+	}
+	return handler(ctx, req)
+}
+
+// acceptedLocales returns the languages of an Accept-Language header, most
+// preferred first, as locale names: "fr-CA" becomes "fr_CA" followed by its
+// language "fr". Languages with a zero quality or a wildcard are left out.
+func acceptedLocales(header string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			languages = append(languages, language{tag, quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	var locales []string
+	for _, l := range languages {
+		locale := strings.Replace(l.tag, "-", "_", -1)
+		locales = append(locales, locale)
+		if i := strings.Index(locale, "_"); i > 0 {
+			locales = append(locales, locale[:i])
+		}
+	}
+	return locales
+}
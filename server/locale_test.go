@@ -0,0 +1,35 @@
+package server
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locale", func() {
+	Describe("acceptedLocales", func() {
+		It("orders languages by quality and adds the language of regional ones", func() {
+			Expect(acceptedLocales("en;q=0.5, fr-CA, de;q=0.8")).To(Equal([]string{"fr_CA", "fr", "de", "en"}))
+		})
+
+		It("skips wildcards and refused languages", func() {
+			Expect(acceptedLocales("*, es;q=0, de")).To(Equal([]string{"de"}))
+			Expect(acceptedLocales("")).To(BeEmpty())
+		})
+	})
+
+	Describe("localeInterceptor", func() {
+		It("passes requests without accept-language through", func() {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ctx, nil
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+			recorded, err := localeInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.User/CreateUser"}, handler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded).To(Equal(ctx))
+		})
+	})
+})
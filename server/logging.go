@@ -0,0 +1,157 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader carries the request ID between services. A new ID is
+// generated when the client does not send one.
+const RequestIDHeader = "x-request-id"
+
+// redactedValue replaces the value of sensitive fields in logs
+const redactedValue = "[REDACTED]"
+
+// SensitiveFields lists the substrings of proto field names whose values are
+// masked in logs, so new fields such as new_password are covered as well.
+// The user filter and subject of audit events are emails, user_agent is
+// masked along with them.
+var SensitiveFields = []string{"password", "token", "email", "secret", "subject", "user"}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request being handled
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// loggingInterceptor logs every RPC as one structured entry with the method,
+// duration, status code and request ID. The request and response are added
+// at debug level with sensitive fields masked.
+func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md[RequestIDHeader]) > 0 {
+		requestID = md[RequestIDHeader][0]
+	}
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	resp, err := handler(ctx, req)
+
+	code := grpc.Code(err)
+	entry := log.WithFields(log.Fields{
+		"method":      info.FullMethod,
+		"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+		"code":        code.String(),
+		"request_id":  requestID,
+	})
+	if withStatus, ok := resp.(interface {
+		GetStatus() string
+	}); ok && err == nil {
+		entry = entry.WithField("result", withStatus.GetStatus())
+	}
+	if log.GetLevel() >= log.DebugLevel {
+		entry = entry.WithFields(log.Fields{
+			"request":  redact(req),
+			"response": redact(resp),
+		})
+	}
+
+	switch code {
+	case codes.OK:
+		entry.Info("RPC handled")
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable:
+		entry.WithError(err).Error("RPC failed")
+	default:
+		entry.WithError(err).Warn("RPC failed")
+	}
+	return resp, err
+}
+
+// redact converts a proto message into a map keyed by proto field name,
+// masking fields whose names contain one of SensitiveFields.
+func redact(message interface{}) interface{} {
+	return redactValue(reflect.ValueOf(message))
+}
+
+func redactValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return redactedValue
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = redactValue(v.Index(i))
+		}
+		return values
+	case reflect.Struct:
+		fields := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			name := protoFieldName(v.Type().Field(i))
+			if name == "" {
+				continue
+			}
+			if isSensitiveField(name) && !isZero(v.Field(i)) {
+				fields[name] = redactedValue
+			} else {
+				fields[name] = redactValue(v.Field(i))
+			}
+		}
+		return fields
+	default:
+		return v.Interface()
+	}
+}
+
+// protoFieldName returns the name from the protobuf struct tag, or an empty
+// string for fields that are not part of the message
+func protoFieldName(field reflect.StructField) string {
+	for _, part := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
+
+func isSensitiveField(name string) bool {
+	for _, sensitive := range SensitiveFields {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	pb "perScoreAuth/perScoreProto/user"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	Describe("redact", func() {
+		It("masks sensitive fields and keeps the rest", func() {
+			redacted := redact(&pb.CreateUserRequest{
+				FirstName: "Jane",
+				Email:     "jane@example.com",
+				Password:  "hunter2",
+				Location:  &pb.CreateUserRequest_Location{City: "Pune"},
+			})
+			Expect(redacted).To(HaveKeyWithValue("first_name", "Jane"))
+			Expect(redacted).To(HaveKeyWithValue("email", redactedValue))
+			Expect(redacted).To(HaveKeyWithValue("password", redactedValue))
+			Expect(redacted).To(HaveKeyWithValue("location", HaveKeyWithValue("city", "Pune")))
+		})
+
+		It("masks the emails of audit event queries", func() {
+			Expect(redact(&pb.ListAuditEventsRequest{User: "jane@example.com", Type: "signup"})).To(And(
+				HaveKeyWithValue("user", redactedValue),
+				HaveKeyWithValue("type", "signup"),
+			))
+			redacted := redact(&pb.ListAuditEventsResponse{Events: []*pb.AuditEvent{{Subject: "jane@example.com", Outcome: "success"}}})
+			Expect(redacted).To(HaveKeyWithValue("events", ContainElement(And(
+				HaveKeyWithValue("subject", redactedValue),
+				HaveKeyWithValue("outcome", "success"),
+			))))
+		})
+
+		It("leaves empty sensitive fields empty", func() {
+			Expect(redact(&pb.GetSessionResponse{Status: "FAILURE"})).To(HaveKeyWithValue("token", ""))
+		})
+	})
+
+	Describe("loggingInterceptor", func() {
+		var output bytes.Buffer
+		info := &grpc.UnaryServerInfo{FullMethod: "/user.User/GetSession"}
+
+		BeforeEach(func() {
+			output.Reset()
+			log.SetOutput(&output)
+			log.SetFormatter(&log.JSONFormatter{})
+			log.SetLevel(log.DebugLevel)
+		})
+
+		AfterEach(func() {
+			log.SetOutput(os.Stderr)
+			log.SetLevel(log.InfoLevel)
+		})
+
+		entry := func() map[string]interface{} {
+			fields := map[string]interface{}{}
+			Expect(json.Unmarshal(output.Bytes(), &fields)).To(Succeed())
+			return fields
+		}
+
+		It("logs the method, status code and request ID without secrets", func() {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDHeader, "req-1"))
+			var handlerRequestID string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerRequestID = RequestIDFromContext(ctx)
+				return &pb.GetSessionResponse{Status: "SUCCESS", Token: "secret-token"}, nil
+			}
+
+			_, err := loggingInterceptor(ctx, &pb.GetSessionRequest{Email: "jane@example.com", Password: "hunter2"}, info, handler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handlerRequestID).To(Equal("req-1"))
+			Expect(output.String()).NotTo(ContainSubstring("hunter2"))
+			Expect(output.String()).NotTo(ContainSubstring("jane@example.com"))
+			Expect(output.String()).NotTo(ContainSubstring("secret-token"))
+
+			fields := entry()
+			Expect(fields).To(HaveKeyWithValue("method", "/user.User/GetSession"))
+			Expect(fields).To(HaveKeyWithValue("code", "OK"))
+			Expect(fields).To(HaveKeyWithValue("request_id", "req-1"))
+			Expect(fields).To(HaveKeyWithValue("result", "SUCCESS"))
+			Expect(fields).To(HaveKey("duration_ms"))
+		})
+
+		It("generates a request ID and records failures", func() {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.PermissionDenied, "denied")
+			}
+			_, err := loggingInterceptor(context.Background(), &pb.GetSessionRequest{}, info, handler)
+			Expect(err).To(HaveOccurred())
+
+			fields := entry()
+			Expect(fields).To(HaveKeyWithValue("code", "PermissionDenied"))
+			Expect(fields).To(HaveKeyWithValue("level", "warning"))
+			Expect(fields["request_id"]).NotTo(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"path"
+	"time"
+
+	"perScoreAuth/metrics"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// DefaultMetricsAddress is the address of the HTTP server exposing /metrics
+const DefaultMetricsAddress = "localhost:9090"
+
+// metricsInterceptor counts requests and measures their latency per method
+// and status code
+func metricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	metrics.ObserveRPC(path.Base(info.FullMethod), grpc.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+// startHTTPServer serves handler in the background, over TLS when
+// tlsConfig is set
+func startHTTPServer(address string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	httpServer := &http.Server{Addr: address, Handler: handler, TLSConfig: tlsConfig}
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Errorf("HTTP server on %s stopped: %+v", address, err)
+		}
+	}()
+	return httpServer
+}
+
+// shutdownHTTPServer stops the operational endpoints, waiting up to timeout
+// for requests being served
+func shutdownHTTPServer(httpServer *http.Server, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Errorf("Error stopping HTTP server on %s: %+v", httpServer.Addr, err)
+	}
+}
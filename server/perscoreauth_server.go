@@ -2,51 +2,182 @@ package server
 
 import (
 	"fmt"
-	"os"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"perScoreAuth/models"
 	pb "perScoreAuth/perScoreProto/user"
 
-	"github.com/jinzhu/gorm"
+	"github.com/golang/protobuf/ptypes"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Server ...
 type Server struct {
 	User models.User
+	// Store keeps users and audit events. It is nil until the database is
+	// reachable, use store() to read it.
+	Store models.Store
+	// IdempotencyWindow is how long responses to requests with an
+	// idempotency key are replayed, DefaultIdempotencyWindow when zero
+	IdempotencyWindow time.Duration
+	// IdempotencyLease is how long a request holds its idempotency key
+	// before a retry may take it over, DefaultIdempotencyLease when zero
+	IdempotencyLease time.Duration
+
+	storeMu sync.RWMutex
+}
+
+// errDatabaseUnavailable is returned while the server is not connected
+var errDatabaseUnavailable = status.Error(codes.Unavailable, "database is not available")
+
+func (s *Server) store() models.Store {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	return s.Store
+}
+
+func (s *Server) setStore(store models.Store) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	s.Store = store
+}
+
+// closeStore closes the store, if any
+func (s *Server) closeStore() {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	if s.Store != nil {
+		if err := s.Store.Close(); err != nil {
+			log.Errorf("Error closing the store: %+v", err)
+		}
+		s.Store = nil
+	}
 }
 
 // CreateUser ...
 func (s *Server) CreateUser(ctx context.Context, in *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
-	fmt.Println("Request:", in)
-	var result *pb.CreateUserResponse
-	dbString := fmt.Sprintf("host=%s dbname=%s user=%s password=%s sslmode=%s", os.Getenv("DEV_HOST"), os.Getenv("DEV_DBNAME"), os.Getenv("DEV_USERNAME"), os.Getenv("DEV_PASSWORD"), os.Getenv("DEV_SSLMODE"))
-	db, err := gorm.Open(os.Getenv("DEV_DB_DRIVER"), dbString)
-	defer db.Close()
-	if err != nil {
-		log.Errorf("Error opening DB connection: %+v", err)
-	} else {
-		result, _ = s.User.CreateInDB(ctx, in, db)
+	store := s.store()
+	if store == nil {
+		return nil, errDatabaseUnavailable
+	}
+	result, err := s.User.CreateInDB(ctx, in, store)
+	switch e := err.(type) {
+	case *models.ConstraintError:
+		if e.Validation == "Taken" {
+			return nil, alreadyExists(result)
+		}
+	case *models.TransactionError:
+		log.Errorf("Error creating user: %+v", err)
+		return nil, status.Error(codes.Unavailable, result.Message)
 	}
-	fmt.Println("Result", result)
 	return result, nil
 }
 
+// alreadyExists returns the AlreadyExists error of a signup refused for a
+// taken field, with the response and its fields as details
+func alreadyExists(response *pb.CreateUserResponse) error {
+	message := response.Message
+	if n := len(response.Fields); n > 0 {
+		message = response.Fields[n-1].Message
+	}
+	st, err := status.New(codes.AlreadyExists, message).WithDetails(response)
+	if err != nil {
+		return status.Error(codes.AlreadyExists, message)
+	}
+	return st.Err()
+}
+
 // GetSession ...
 func (s *Server) GetSession(ctx context.Context, in *pb.GetSessionRequest) (*pb.GetSessionResponse, error) {
-	fmt.Println("Request:", in)
-	var result *pb.GetSessionResponse
-	dbString := fmt.Sprintf("host=%s dbname=%s user=%s password=%s sslmode=%s", os.Getenv("DEV_HOST"), os.Getenv("DEV_DBNAME"), os.Getenv("DEV_USERNAME"), os.Getenv("DEV_PASSWORD"), os.Getenv("DEV_SSLMODE"))
-	db, err := gorm.Open(os.Getenv("DEV_DB_DRIVER"), dbString)
-	defer db.Close()
-	if err != nil {
-		log.Errorf("Error opening DB connection: %+v", err)
-	} else {
-		models.SetupDatabase(db)
-		result, _ = s.User.CreateSession(ctx, in, db)
+	store := s.store()
+	if store == nil {
+		return nil, errDatabaseUnavailable
+	}
+	result, _ := s.User.CreateSession(ctx, in, store)
+	return result, nil
+}
+
+// ChangePassword ...
+func (s *Server) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	store := s.store()
+	if store == nil {
+		return nil, errDatabaseUnavailable
+	}
+	result, err := s.User.ChangePassword(ctx, in, store)
+	if err != nil && !passwordChangeRefused(err) {
+		log.Errorf("Error changing password: %+v", err)
+		return nil, status.Error(codes.Unavailable, result.Message)
 	}
-	fmt.Println("Result", result)
 	return result, nil
 }
+
+// passwordChangeRefused reports whether err is ChangePassword refusing the
+// request, rather than a failure of the store
+func passwordChangeRefused(err error) bool {
+	if _, ok := err.(*models.PasswordPolicyError); ok {
+		return true
+	}
+	return err == models.ErrInvalidCredentials || err == models.ErrPasswordReused
+}
+
+// RequirePasswordChange ...
+func (s *Server) RequirePasswordChange(ctx context.Context, in *pb.RequirePasswordChangeRequest) (*pb.RequirePasswordChangeResponse, error) {
+	filter := models.UserFilter{Emails: in.Emails, Role: in.Role}
+	if in.CreatedBefore != nil {
+		createdBefore, err := ptypes.Timestamp(in.CreatedBefore)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid created_before: %v", err)
+		}
+		filter.CreatedBefore = createdBefore
+	}
+
+	store := s.store()
+	if store == nil {
+		return nil, errDatabaseUnavailable
+	}
+	users, err := models.RequirePasswordChange(ctx, store, filter, in.All)
+	if err == models.ErrNoUsersSelected {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		log.Errorf("Error requiring password changes: %+v", err)
+		return nil, status.Errorf(codes.Internal, "requiring password changes failed after %d users", len(users))
+	}
+	return &pb.RequirePasswordChangeResponse{
+		Status:  "SUCCESS",
+		Message: fmt.Sprintf("%d users must change their password", len(users)),
+		Count:   int32(len(users)),
+	}, nil
+}
+
+// ListAuditEvents ...
+func (s *Server) ListAuditEvents(ctx context.Context, in *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	filter, err := auditEventFilter(in)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid time range: %v", err)
+	}
+
+	store := s.store()
+	if store == nil {
+		return nil, errDatabaseUnavailable
+	}
+	events, err := store.ListAuditEvents(filter)
+	if err != nil {
+		log.Errorf("Error listing audit events: %+v", err)
+		return nil, status.Error(codes.Internal, "listing audit events failed")
+	}
+
+	response := &pb.ListAuditEventsResponse{
+		Status:  "SUCCESS",
+		Message: fmt.Sprintf("%d audit events found", len(events)),
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, auditEventProto(event))
+	}
+	return response, nil
+}
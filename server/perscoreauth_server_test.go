@@ -0,0 +1,69 @@
+package server
+
+import (
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server *Server
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		server = &Server{Store: models.NewMemoryStore()}
+		ctx = models.WithAuditContext(context.Background(), models.AuditContext{Tenant: "acme", PeerIP: "10.0.0.1"})
+		models.PasswordCost = bcrypt.MinCost
+	})
+
+	AfterEach(func() {
+		models.PasswordCost = bcrypt.DefaultCost
+	})
+
+	It("signs users up and in, and audits it", func() {
+		created, err := server.CreateUser(ctx, &pb.CreateUserRequest{
+			FirstName: "Ada",
+			LastName:  "Lovelace",
+			Email:     "ada@example.com",
+			Password:  "s3cret-Passw0rd",
+			Age:       36,
+			Role:      "admin",
+			Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.Status).To(Equal("SUCCESS"))
+
+		session, err := server.GetSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com", Password: "s3cret-Passw0rd"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.Status).To(Equal("SUCCESS"))
+		Expect(session.Token).NotTo(BeEmpty())
+
+		session, err = server.GetSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com", Password: "wrong"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.Status).To(Equal("FAILURE"))
+
+		events, err := server.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{User: "ada@example.com"})
+		Expect(err).NotTo(HaveOccurred())
+		var types []string
+		for _, event := range events.Events {
+			Expect(event.PeerIp).To(Equal("10.0.0.1"))
+			types = append(types, event.Type+":"+event.Outcome)
+		}
+		Expect(types).To(Equal([]string{"login_failure:failure", "login_success:success", "signup:success"}))
+	})
+
+	It("answers Unavailable once the store is closed", func() {
+		server.closeStore()
+		_, err := server.GetSession(ctx, &pb.GetSessionRequest{Email: "ada@example.com"})
+		Expect(grpc.Code(err)).To(Equal(codes.Unavailable))
+	})
+})
@@ -1,27 +1,267 @@
 package server
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"perScoreAuth/metrics"
 	pb "perScoreAuth/perScoreProto/user"
+	"sync"
+	"syscall"
+	"time"
 
+	logrus "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-const address = "localhost:6050"
+// DefaultAddress is the address the server listens on when none is configured
+const DefaultAddress = "localhost:6050"
+
+// DefaultShutdownTimeout is how long in-flight requests are drained on shutdown
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Config holds the settings used by StartServer
+type Config struct {
+	Address string
+	// Env selects the database, see models.OpenDatabase
+	Env string
+	// GatewayAddress is where the HTTP/JSON gateway is served, empty
+	// disables it
+	GatewayAddress string
+	// MetricsAddress is where /metrics, /healthz and /readyz are served,
+	// empty disables them
+	MetricsAddress string
+	// HealthCheckInterval is how often the database is probed
+	HealthCheckInterval time.Duration
+	// ShutdownTimeout bounds how long in-flight requests are drained on
+	// SIGINT or SIGTERM before they are cancelled
+	ShutdownTimeout time.Duration
+
+	// TLSCertFile and TLSKeyFile enable TLS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile enables client certificate verification against the CA bundle
+	TLSClientCAFile string
+	// RequireClientCert rejects connections that do not present a client certificate
+	RequireClientCert bool
+	// InsecureAllowAll lets the server start without caller authorization,
+	// when TLS or the client CA bundle is not configured. Any client may then
+	// call every RPC and name any tenant.
+	InsecureAllowAll bool
+
+	// Authorization maps RPC names to the callers allowed to invoke them
+	Authorization map[string][]string
+	// Tenants lists the tenants callers may act for, DefaultTenants when
+	// empty. It applies with caller authorization.
+	Tenants []TenantBinding
+
+	// RedactFields overrides SensitiveFields when set
+	RedactFields []string
+
+	// IdempotencyWindow is how long responses to requests with an
+	// idempotency key are replayed
+	IdempotencyWindow time.Duration
+	// IdempotencyLease is how long a request holds its idempotency key
+	// before a retry may take it over
+	IdempotencyLease time.Duration
+}
+
+// checkCallerAuthorization returns why callers would not be authorized with
+// config, unless config allows it
+func checkCallerAuthorization(config Config) error {
+	switch {
+	case config.InsecureAllowAll:
+		return nil
+	case config.TLSCertFile == "" || config.TLSKeyFile == "":
+		return errors.New("no TLS certificate configured, callers cannot be authorized; pass --insecure-allow-all to serve every client anyway")
+	case config.TLSClientCAFile == "":
+		return errors.New("no client CA bundle configured, callers cannot be authorized; pass --insecure-allow-all to serve every client anyway")
+	}
+	return nil
+}
 
 // StartServer ...
-func StartServer() {
-	lis, err := net.Listen("tcp", address)
+func StartServer(config Config) {
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+
+	if config.Env == "" {
+		config.Env = "dev"
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = DefaultHealthCheckInterval
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if len(config.RedactFields) > 0 {
+		SensitiveFields = config.RedactFields
+	}
+	if config.IdempotencyWindow <= 0 {
+		config.IdempotencyWindow = DefaultIdempotencyWindow
+	}
+	if config.IdempotencyLease <= 0 {
+		config.IdempotencyLease = DefaultIdempotencyLease
+	}
+	if err := checkCallerAuthorization(config); err != nil {
+		log.Fatalf("refusing to start: %v", err)
+	}
+
+	// The database is connected in the background, requests fail with
+	// Unavailable and health checks report NOT_SERVING until it is ready
+	server := &Server{IdempotencyWindow: config.IdempotencyWindow, IdempotencyLease: config.IdempotencyLease}
+	health := &healthServer{}
+	monitor := newDatabaseMonitor(config.Env, config.HealthCheckInterval, server, health)
+
+	var httpServer *http.Server
+	if config.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.HandleFunc("/healthz", health.ServeLive)
+		mux.HandleFunc("/readyz", health.ServeReady)
+		httpServer = startHTTPServer(config.MetricsAddress, mux, nil)
+		logrus.Infof("Serving metrics and health checks on %s", config.MetricsAddress)
+	}
+
+	var opts []grpc.ServerOption
+	var reloader *certReloader
+	var gatewayTLS *tls.Config
+	var policy *callerPolicy
+	var tenants tenantPolicy
+
+	if config.TLSCertFile != "" && config.TLSKeyFile != "" {
+		var err error
+		reloader, err = newCertReloader(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("failed to load TLS credentials: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.TLSConfig(config.RequireClientCert))))
+		gatewayTLS = reloader.TLSConfig(config.RequireClientCert, "h2", "http/1.1")
+
+		if config.TLSClientCAFile != "" {
+			p := newCallerPolicy(config.Authorization)
+			policy = &p
+			tenants = newTenantPolicy(config.Tenants)
+		} else {
+			logrus.Warn("No client CA bundle configured, caller authorization is disabled and x-tenant-id is trusted")
+		}
+	} else {
+		logrus.Warn("No TLS certificate configured, serving in plaintext with caller authorization disabled and x-tenant-id trusted")
+	}
+
+	interceptor := serverInterceptor(server, policy, tenants)
+
+	lis, err := net.Listen("tcp", config.Address)
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	fmt.Println("perScoreAuth server started on :6060 ...")
+	fmt.Printf("perScoreAuth server started on %s ...\n", config.Address)
+
+	s := newGRPCServer(server, health, interceptor, opts...)
+
+	var gatewayServer *http.Server
+	if config.GatewayAddress != "" {
+		gatewayServer = startHTTPServer(config.GatewayAddress, newGateway(server, interceptor), gatewayTLS)
+		logrus.Infof("Serving the HTTP/JSON gateway on %s", config.GatewayAddress)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
 
-	// Creates a new gRPC server
-	s := grpc.NewServer()
-	pb.RegisterUserServer(s, &Server{})
-	s.Serve(lis)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(lis)
+	}()
 
+	select {
+	case sig := <-signals:
+		logrus.Infof("Received %s, shutting down", sig)
+	case err := <-serveErr:
+		logrus.Errorf("gRPC server stopped: %+v", err)
+	}
+
+	// Stop accepting requests first and drain the ones in flight, then stop
+	// what they depend on: the background workers and finally the database
+	monitor.stop()
+	health.SetServing(false, "shutting down")
+	var draining sync.WaitGroup
+	if gatewayServer != nil {
+		draining.Add(1)
+		go func() {
+			defer draining.Done()
+			shutdownHTTPServer(gatewayServer, config.ShutdownTimeout)
+		}()
+	}
+	if !stopGRPCServer(s, config.ShutdownTimeout) {
+		logrus.Warnf("Requests still running after %s were cancelled", config.ShutdownTimeout)
+	}
+	draining.Wait()
+	if httpServer != nil {
+		shutdownHTTPServer(httpServer, config.ShutdownTimeout)
+	}
+	if reloader != nil {
+		reloader.Close()
+	}
+	server.closeStore()
+	logrus.Info("perScoreAuth server stopped")
+}
+
+// NewGRPCServer returns a gRPC server serving server and a health service
+// that reports SERVING, with requests logged, measured and audited as in
+// StartServer. Callers are not authorized since that needs TLS. It lets the
+// service be embedded or tested in-process on any listener.
+func NewGRPCServer(server *Server, opts ...grpc.ServerOption) *grpc.Server {
+	return newGRPCServer(server, &healthServer{serving: true}, serverInterceptor(server, nil, nil), opts...)
+}
+
+func newGRPCServer(server *Server, health *healthServer, interceptor grpc.UnaryServerInterceptor, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append(opts, grpc.UnaryInterceptor(interceptor))...)
+	pb.RegisterUserServer(s, server)
+	healthpb.RegisterHealthServer(s, health)
+	return s
+}
+
+// serverInterceptor chains the interceptors every request to server goes
+// through, with caller authorization when policy is set and the tenant bound
+// to the caller when tenants is set
+func serverInterceptor(server *Server, policy *callerPolicy, tenants tenantPolicy) grpc.UnaryServerInterceptor {
+	interceptors := []grpc.UnaryServerInterceptor{loggingInterceptor, metricsInterceptor}
+	if policy != nil {
+		interceptors = append(interceptors, policy.UnaryServerInterceptor)
+	}
+	if tenants != nil {
+		interceptors = append(interceptors, tenants.UnaryServerInterceptor)
+	}
+	interceptors = append(interceptors, auditContextInterceptor, localeInterceptor, server.idempotencyInterceptor)
+	return chainUnaryInterceptors(interceptors...)
+}
+
+// stopGRPCServer waits up to timeout for in-flight requests to finish, then
+// closes the remaining connections. It reports whether all requests finished.
+func stopGRPCServer(s *grpc.Server, timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return true
+	case <-timer.C:
+		s.Stop()
+		<-stopped
+		return false
+	}
 }
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// blockingHealthServer holds every Check until release is closed or the
+// request is cancelled
+type blockingHealthServer struct {
+	started  chan struct{}
+	release  chan struct{}
+	finished chan struct{}
+}
+
+func (h *blockingHealthServer) Check(ctx context.Context, in *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	close(h.started)
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+	}
+	close(h.finished)
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+var _ = Describe("stopGRPCServer", func() {
+	var (
+		s       *grpc.Server
+		conn    *grpc.ClientConn
+		blocked *blockingHealthServer
+		result  chan error
+	)
+
+	BeforeEach(func() {
+		lis, err := net.Listen("tcp", "localhost:0")
+		Expect(err).NotTo(HaveOccurred())
+		blocked = &blockingHealthServer{started: make(chan struct{}), release: make(chan struct{}), finished: make(chan struct{})}
+		s = grpc.NewServer()
+		healthpb.RegisterHealthServer(s, blocked)
+		go s.Serve(lis)
+
+		conn, err = grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		Expect(err).NotTo(HaveOccurred())
+
+		result = make(chan error, 1)
+		go func() {
+			_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			result <- err
+		}()
+		Eventually(blocked.started).Should(BeClosed())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+	})
+
+	It("waits for in-flight requests to finish", func() {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(blocked.release)
+		}()
+		Expect(stopGRPCServer(s, 5*time.Second)).To(BeTrue())
+		Expect(blocked.finished).To(BeClosed())
+	})
+
+	It("cancels requests still running after the timeout", func() {
+		Expect(stopGRPCServer(s, 50*time.Millisecond)).To(BeFalse())
+		Eventually(result).Should(Receive(HaveOccurred()))
+	})
+})
+
+var _ = Describe("checkCallerAuthorization", func() {
+	It("requires TLS with a client CA bundle unless insecure serving is allowed", func() {
+		tls := Config{TLSCertFile: "tls.crt", TLSKeyFile: "tls.key"}
+		Expect(checkCallerAuthorization(Config{})).To(MatchError(ContainSubstring("no TLS certificate")))
+		Expect(checkCallerAuthorization(tls)).To(MatchError(ContainSubstring("no client CA bundle")))
+
+		tls.TLSClientCAFile = "ca.crt"
+		Expect(checkCallerAuthorization(tls)).To(Succeed())
+		Expect(checkCallerAuthorization(Config{InsecureAllowAll: true})).To(Succeed())
+	})
+})
@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"github.com/golang/protobuf/ptypes"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServer_ListAuditEvents(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+		if _, err := h.Client.CreateUser(ctx, CreateUserRequest("ada@example.com")); err != nil {
+			t.Fatalf("Failed to call CreateUser: %+v", err)
+		}
+		GetSession(t, h.Client, "ada@example.com", Password)
+		h.Client.GetSession(context.Background(), &pb.GetSessionRequest{Email: "ada@example.com", Password: "wrong"})
+		CreateUser(t, h.Client, CreateUserRequest("bob@example.com"))
+
+		tests := []struct {
+			name     string
+			req      *pb.ListAuditEventsRequest
+			expected []string
+		}{
+			{"all", &pb.ListAuditEventsRequest{}, []string{"signup", "login_failure", "login_success", "signup"}},
+			{"by user", &pb.ListAuditEventsRequest{User: "ada@example.com"}, []string{"login_failure", "login_success", "signup"}},
+			{"by type", &pb.ListAuditEventsRequest{Type: models.AuditSignup}, []string{"signup", "signup"}},
+			{"limited", &pb.ListAuditEventsRequest{Limit: 1}, []string{"signup"}},
+			{"in the future", &pb.ListAuditEventsRequest{From: timestamp(t, time.Now().Add(time.Hour))}, nil},
+			{"in the past", &pb.ListAuditEventsRequest{To: timestamp(t, time.Now().Add(-time.Hour))}, nil},
+		}
+
+		for _, test := range tests {
+			response, err := h.Client.ListAuditEvents(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("%s: Failed to call ListAuditEvents: %+v", test.name, err)
+			}
+			CheckStatus(t, response.Status, "SUCCESS")
+			CheckLength(t, test.name+" audit events", len(response.Events), len(test.expected))
+			for i, event := range response.Events {
+				CheckStatus(t, event.Type, test.expected[i])
+			}
+		}
+
+		events := ListAuditEvents(t, h.Client, "bob@example.com")
+		switch {
+		case events[0].Actor != "bob@example.com":
+			t.Fatalf("Invalid actor, expected the user, got, %s", events[0].Actor)
+		case events[0].UserAgent == "":
+			t.Fatalf("Invalid user agent, expected the client's, got none")
+		case events[0].CreatedAt == nil:
+			t.Fatalf("Invalid event, expected a creation time")
+		}
+	}, t)
+}
+
+func TestServer_ListAuditEventsInvalidRange(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		req := &pb.ListAuditEventsRequest{From: &tspb.Timestamp{Seconds: -1 << 62}}
+		_, err := h.Client.ListAuditEvents(context.Background(), req)
+		CheckCode(t, err, codes.InvalidArgument)
+	}, t)
+}
+
+func TestServer_ListAuditEventsWithoutDatabase(t *testing.T) {
+	testRunnerWithoutDatabase(func(t *testing.T, h *harness) {
+		_, err := h.Client.ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{})
+		CheckCode(t, err, codes.Unavailable)
+	}, t)
+}
+
+func timestamp(t *testing.T, at time.Time) *tspb.Timestamp {
+	ts, err := ptypes.TimestampProto(at)
+	if err != nil {
+		t.Fatalf("Invalid time %s: %+v", at, err)
+	}
+	return ts
+}
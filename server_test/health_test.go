@@ -0,0 +1,24 @@
+package server_test
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestServer_Health(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		for _, service := range []string{"", "user.User"} {
+			response, err := h.Health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("Failed to check %q: %+v", service, err)
+			}
+			CheckStatus(t, response.Status, healthpb.HealthCheckResponse_SERVING)
+		}
+
+		_, err := h.Health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "unknown.Service"})
+		CheckCode(t, err, codes.NotFound)
+	}, t)
+}
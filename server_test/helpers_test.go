@@ -1,9 +1,18 @@
-package identity_server_test
+package server_test
 
 import (
 	"testing"
+
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
+// Password is the password of users created by the fixtures
+const Password = "s3cret-Passw0rd"
+
 func CheckLength(t *testing.T, name string, actualLength int, expectedLength int) {
 	if actualLength != expectedLength {
 		t.Fatalf("Invalid number of %s, expected %d, got, %d", name, expectedLength, actualLength)
@@ -15,3 +24,82 @@ func CheckStatus(t *testing.T, status, expectedStatus interface{}) {
 		t.Fatalf("Invalid Status, expected %s, got, %s", expectedStatus, status)
 	}
 }
+
+func CheckCode(t *testing.T, err error, expectedCode codes.Code) {
+	if code := grpc.Code(err); code != expectedCode {
+		t.Fatalf("Invalid code, expected %s, got, %s (%v)", expectedCode, code, err)
+	}
+}
+
+// CheckFields fails unless the field validations are exactly expected, given
+// as "name:Validation"
+func CheckFields(t *testing.T, fields []*pb.CreateUserResponse_Field, expected ...string) {
+	actual := map[string]bool{}
+	for _, field := range fields {
+		actual[field.Name+":"+field.Validation] = true
+	}
+	CheckLength(t, "fields", len(fields), len(expected))
+	for _, field := range expected {
+		if !actual[field] {
+			t.Fatalf("Missing field %s, got, %v", field, fields)
+		}
+	}
+}
+
+// CreateUserRequest returns a valid signup for email
+func CreateUserRequest(email string) *pb.CreateUserRequest {
+	return &pb.CreateUserRequest{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     email,
+		Password:  Password,
+		Age:       36,
+		Role:      "responder",
+		Location:  &pb.CreateUserRequest_Location{City: "London", Country: "GB"},
+	}
+}
+
+// CreateUser signs req up and fails the test unless it succeeds
+func CreateUser(t *testing.T, client pb.UserClient, req *pb.CreateUserRequest) *pb.CreateUserResponse {
+	response, err := client.CreateUser(context.Background(), req)
+	switch {
+	case err != nil:
+		t.Fatalf("Failed to call CreateUser: %+v", err)
+	case response.Status != "SUCCESS":
+		t.Fatalf("Failed to create %s: %s %v", req.Email, response.Message, response.Fields)
+	}
+	return response
+}
+
+// GetSession logs in and fails the test unless it succeeds
+func GetSession(t *testing.T, client pb.UserClient, email, password string) *pb.GetSessionResponse {
+	response, err := client.GetSession(context.Background(), &pb.GetSessionRequest{Email: email, Password: password})
+	switch {
+	case err != nil:
+		t.Fatalf("Failed to call GetSession: %+v", err)
+	case response.Status != "SUCCESS":
+		t.Fatalf("Failed to log in as %s: %s", email, response.Message)
+	}
+	return response
+}
+
+// ListAuditEvents returns the audit events of user, newest first
+func ListAuditEvents(t *testing.T, client pb.UserClient, user string) []*pb.AuditEvent {
+	response, err := client.ListAuditEvents(context.Background(), &pb.ListAuditEventsRequest{User: user})
+	if err != nil {
+		t.Fatalf("Failed to call ListAuditEvents: %+v", err)
+	}
+	return response.Events
+}
+
+// CheckAuditEvents fails unless the audit events of user are exactly
+// expected, newest first, given as "type:outcome:reason"
+func CheckAuditEvents(t *testing.T, client pb.UserClient, user string, expected ...string) {
+	events := ListAuditEvents(t, client, user)
+	CheckLength(t, "audit events", len(events), len(expected))
+	for i, event := range events {
+		if actual := event.Type + ":" + event.Outcome + ":" + event.Reason; actual != expected[i] {
+			t.Fatalf("Invalid audit event %d, expected %s, got, %s", i, expected[i], actual)
+		}
+	}
+}
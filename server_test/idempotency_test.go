@@ -0,0 +1,39 @@
+package server_test
+
+import (
+	"testing"
+
+	"perScoreAuth/server"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServer_CreateUserIdempotencyKey(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(server.IdempotencyKeyHeader, "signup-1"))
+		req := CreateUserRequest("ada@example.com")
+		first, err := h.Client.CreateUser(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to call CreateUser: %+v", err)
+		}
+		retry, err := h.Client.CreateUser(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to retry CreateUser: %+v", err)
+		}
+		CheckStatus(t, retry.Status, first.Status)
+		CheckStatus(t, retry.Message, first.Message)
+		CheckAuditEvents(t, h.Client, req.Email, "signup:success:")
+
+		_, err = h.Client.CreateUser(ctx, CreateUserRequest("bob@example.com"))
+		CheckCode(t, err, codes.Aborted)
+
+		ctx = metadata.NewOutgoingContext(context.Background(), metadata.Pairs(server.IdempotencyKeyHeader, "signup-1", "x-tenant-id", "acme"))
+		response, err := h.Client.CreateUser(ctx, CreateUserRequest("bob@example.com"))
+		if err != nil {
+			t.Fatalf("Failed to call CreateUser for another tenant: %+v", err)
+		}
+		CheckStatus(t, response.Status, "SUCCESS")
+	}, t)
+}
@@ -0,0 +1,91 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+func TestServer_RequirePasswordChange(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		CreateUser(t, h.Client, CreateUserRequest("ada@example.com"))
+		bob := CreateUserRequest("bob@example.com")
+		bob.Role = "admin"
+		CreateUser(t, h.Client, bob)
+
+		_, err := h.Client.RequirePasswordChange(context.Background(), &pb.RequirePasswordChangeRequest{})
+		CheckCode(t, err, codes.InvalidArgument)
+
+		response, err := h.Client.RequirePasswordChange(context.Background(), &pb.RequirePasswordChangeRequest{
+			Role:          "admin",
+			CreatedBefore: timestamp(t, time.Now().Add(time.Minute)),
+		})
+		if err != nil {
+			t.Fatalf("Failed to call RequirePasswordChange: %+v", err)
+		}
+		CheckLength(t, "flagged users", int(response.Count), 1)
+
+		session, err := h.Client.GetSession(context.Background(), &pb.GetSessionRequest{Email: bob.Email, Password: Password})
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to call GetSession: %+v", err)
+		case session.Status != "PASSWORD_CHANGE_REQUIRED" || !session.PasswordChangeRequired || session.Token != "":
+			t.Fatalf("Invalid response, expected a password change to be required, got, %+v", session)
+		}
+		GetSession(t, h.Client, "ada@example.com", Password)
+		CheckAuditEvents(t, h.Client, bob.Email,
+			"login_blocked:failure:password_change_required", "password_change_required:success:", "signup:success:")
+	}, t)
+}
+
+func TestServer_ChangePassword(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		CreateUser(t, h.Client, CreateUserRequest("ada@example.com"))
+		if _, err := models.RequirePasswordChange(context.Background(), h.Store, models.UserFilter{Emails: []string{"ada@example.com"}}, false); err != nil {
+			t.Fatalf("Failed to require a password change: %+v", err)
+		}
+
+		tests := []struct {
+			password    string
+			newPassword string
+			status      string
+			fields      []string
+		}{
+			{"wrong", "an0ther-Passw0rd", "FAILURE", nil},
+			{Password, "ada", "FAILURE", []string{"new_password:MinLength", "new_password:PersonalInfo"}},
+			{Password, Password, "FAILURE", []string{"new_password:Reused"}},
+			{Password, "an0ther-Passw0rd", "SUCCESS", nil},
+		}
+		for _, test := range tests {
+			response, err := h.Client.ChangePassword(context.Background(), &pb.ChangePasswordRequest{
+				Email:       "ada@example.com",
+				Password:    test.password,
+				NewPassword: test.newPassword,
+			})
+			if err != nil {
+				t.Fatalf("Failed to call ChangePassword: %+v", err)
+			}
+			CheckStatus(t, response.Status, test.status)
+			CheckLength(t, "fields", len(response.Fields), len(test.fields))
+			for i, field := range response.Fields {
+				CheckStatus(t, field.Name+":"+field.Validation, test.fields[i])
+			}
+		}
+
+		GetSession(t, h.Client, "ada@example.com", "an0ther-Passw0rd")
+	}, t)
+}
+
+func TestServer_ChangePasswordWithoutDatabase(t *testing.T) {
+	testRunnerWithoutDatabase(func(t *testing.T, h *harness) {
+		_, err := h.Client.ChangePassword(context.Background(), &pb.ChangePasswordRequest{})
+		CheckCode(t, err, codes.Unavailable)
+		_, err = h.Client.RequirePasswordChange(context.Background(), &pb.RequirePasswordChangeRequest{All: true})
+		CheckCode(t, err, codes.Unavailable)
+	}, t)
+}
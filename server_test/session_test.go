@@ -0,0 +1,110 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+	"perScoreAuth/server"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServer_GetSession(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		req := CreateUserRequest("ada@example.com")
+		CreateUser(t, h.Client, req)
+
+		response := GetSession(t, h.Client, req.Email, Password)
+		token, err := models.VerifyToken(response.Token, time.Now())
+		switch {
+		case err != nil:
+			t.Fatalf("Invalid token: %+v", err)
+		case token.Email != req.Email || token.Role != req.Role:
+			t.Fatalf("Invalid token, expected %s and %s, got, %+v", req.Email, req.Role, token)
+		case token.TTL != models.SessionDuration:
+			t.Fatalf("Invalid token TTL, expected %s, got, %s", models.SessionDuration, token.TTL)
+		}
+		CheckAuditEvents(t, h.Client, req.Email, "login_success:success:", "signup:success:")
+	}, t)
+}
+
+func TestServer_GetSessionFailures(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		CreateUser(t, h.Client, CreateUserRequest("ada@example.com"))
+		CreateUser(t, h.Client, CreateUserRequest("bob@example.com"))
+		if err := models.DisableUser(context.Background(), h.Store, "bob@example.com"); err != nil {
+			t.Fatalf("Failed to disable the user: %+v", err)
+		}
+
+		tests := []struct {
+			email    string
+			password string
+			reason   string
+		}{
+			{"ada@example.com", "wrong", "wrong_password"},
+			{"ada@example.com", "", "wrong_password"},
+			{"nobody@example.com", Password, "unknown_email"},
+			{"", "", "unknown_email"},
+			{"bob@example.com", Password, "disabled"},
+		}
+
+		for _, test := range tests {
+			response, err := h.Client.GetSession(context.Background(), &pb.GetSessionRequest{Email: test.email, Password: test.password})
+			switch {
+			case err != nil:
+				t.Fatalf("Failed to call GetSession: %+v", err)
+			case response.Status != "FAILURE" || response.Token != "":
+				t.Fatalf("Invalid response for %q, expected a failure, got, %+v", test.email, response)
+			}
+			CheckStatus(t, response.Message, "Invalid email and password combination!")
+
+			events := ListAuditEvents(t, h.Client, test.email)
+			if len(events) == 0 || events[0].Type != models.AuditLoginFailure || events[0].Reason != test.reason {
+				t.Fatalf("Invalid audit events for %q, expected a %s login failure, got, %v", test.email, test.reason, events)
+			}
+		}
+	}, t)
+}
+
+func TestServer_GetSessionUpgradesLegacyPasswords(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		CreateUser(t, h.Client, CreateUserRequest("ada@example.com"))
+		user, err := h.Store.FindUserByEmail("ada@example.com")
+		if err != nil {
+			t.Fatalf("Failed to find the user: %+v", err)
+		}
+		if err := h.Store.UpdatePassword(user.ID, models.Encrypt(Password)); err != nil {
+			t.Fatalf("Failed to store a legacy password: %+v", err)
+		}
+
+		GetSession(t, h.Client, "ada@example.com", Password)
+		if user, _ = h.Store.FindUserByEmail("ada@example.com"); models.PasswordAlgorithm(user.Password) != models.PasswordBcrypt {
+			t.Fatalf("Invalid password hash, expected bcrypt, got, %s", models.PasswordAlgorithm(user.Password))
+		}
+		GetSession(t, h.Client, "ada@example.com", Password)
+	}, t)
+}
+
+func TestServer_GetSessionRequestID(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(server.RequestIDHeader, "req-42"))
+		var header metadata.MD
+		if _, err := h.Client.GetSession(ctx, &pb.GetSessionRequest{}, grpc.Header(&header)); err != nil {
+			t.Fatalf("Failed to call GetSession: %+v", err)
+		}
+		CheckLength(t, "request IDs", len(header[server.RequestIDHeader]), 1)
+		CheckStatus(t, header[server.RequestIDHeader][0], "req-42")
+	}, t)
+}
+
+func TestServer_GetSessionWithoutDatabase(t *testing.T) {
+	testRunnerWithoutDatabase(func(t *testing.T, h *harness) {
+		_, err := h.Client.GetSession(context.Background(), &pb.GetSessionRequest{Email: "ada@example.com", Password: Password})
+		CheckCode(t, err, codes.Unavailable)
+	}, t)
+}
@@ -0,0 +1,165 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"perScoreAuth/models"
+	pb "perScoreAuth/perScoreProto/user"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_CreateUser(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		req := CreateUserRequest("ada@example.com")
+		response := CreateUser(t, h.Client, req)
+		CheckStatus(t, response.Message, "You have signed up successfully!")
+		CheckLength(t, "fields", len(response.Fields), 0)
+
+		user, err := h.Store.FindUserByEmail(req.Email)
+		switch {
+		case err != nil:
+			t.Fatalf("Failed to find the user: %+v", err)
+		case user.Location.City != "London" || user.Role != "responder":
+			t.Fatalf("Invalid user, got, %+v", user)
+		case models.PasswordAlgorithm(user.Password) != models.PasswordBcrypt:
+			t.Fatalf("Invalid password hash, expected bcrypt, got, %s", models.PasswordAlgorithm(user.Password))
+		}
+		CheckAuditEvents(t, h.Client, req.Email, "signup:success:")
+	}, t)
+}
+
+func TestServer_CreateUserValidation(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		tests := []struct {
+			name   string
+			change func(req *pb.CreateUserRequest)
+			fields []string
+		}{
+			{"empty request", func(req *pb.CreateUserRequest) { *req = pb.CreateUserRequest{} }, []string{
+				"first_name:Required", "last_name:Required", "email:Required", "password:Required",
+				"age:Required", "role:Required", "city:Required", "country:Required",
+			}},
+			{"invalid values", func(req *pb.CreateUserRequest) {
+				req.Email = "ada.example.com"
+				req.Age = 150
+				req.LastName = strings.Repeat("l", 101)
+				req.Location.Country = "XX"
+			}, []string{"email:Email", "age:Max", "last_name:Max", "country:Country"}},
+			{"no password", func(req *pb.CreateUserRequest) { req.Password = "" }, []string{"password:Required"}},
+			{"no location", func(req *pb.CreateUserRequest) { req.Location = nil }, []string{"city:Required", "country:Required"}},
+		}
+
+		for _, test := range tests {
+			req := CreateUserRequest("ada@example.com")
+			test.change(req)
+			response, err := h.Client.CreateUser(context.Background(), req)
+			if err != nil {
+				t.Fatalf("%s: Failed to call CreateUser: %+v", test.name, err)
+			}
+			CheckStatus(t, response.Status, "FAILURE")
+			CheckFields(t, response.Fields, test.fields...)
+		}
+
+		if _, err := h.Store.FindUserByEmail("ada@example.com"); err != models.ErrUserNotFound {
+			t.Fatalf("Invalid user lookup, expected %v, got, %v", models.ErrUserNotFound, err)
+		}
+		CheckAuditEvents(t, h.Client, "ada@example.com",
+			"signup:failure:validation_failed", "signup:failure:validation_failed")
+	}, t)
+}
+
+func TestServer_CreateUserLocalizedMessages(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		req := CreateUserRequest("ada@example.com")
+		req.FirstName = ""
+		req.Password = "ada"
+		tests := []struct {
+			acceptLanguage string
+			messages       []string
+		}{
+			{"fr-CA, en;q=0.5", []string{"Le champ prénom est obligatoire", "Le mot de passe doit contenir au moins 8 caractères"}},
+			{"pt-BR, de;q=0.8", []string{"Vorname ist ein Pflichtfeld", "Das Passwort muss mindestens 8 Zeichen lang sein"}},
+			{"ja", []string{"First name is required", "Password must be at least 8 characters long"}},
+		}
+
+		for _, test := range tests {
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("accept-language", test.acceptLanguage))
+			response, err := h.Client.CreateUser(ctx, req)
+			if err != nil {
+				t.Fatalf("%s: Failed to call CreateUser: %+v", test.acceptLanguage, err)
+			}
+			messages := map[string]bool{}
+			for _, field := range response.Fields {
+				messages[field.Message] = true
+			}
+			for _, message := range test.messages {
+				if !messages[message] {
+					t.Fatalf("%s: Missing message %q, got, %v", test.acceptLanguage, message, response.Fields)
+				}
+			}
+		}
+	}, t)
+}
+
+func TestServer_CreateUserDuplicateEmail(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		req := CreateUserRequest("ada@example.com")
+		CreateUser(t, h.Client, req)
+
+		_, err := h.Client.CreateUser(context.Background(), req)
+		CheckCode(t, err, codes.AlreadyExists)
+		CheckStatus(t, grpc.ErrorDesc(err), "Email is already taken")
+		st, _ := status.FromError(err)
+		details := st.Details()
+		CheckLength(t, "details", len(details), 1)
+		response, ok := details[0].(*pb.CreateUserResponse)
+		if !ok {
+			t.Fatalf("Invalid details, expected a CreateUserResponse, got, %v", details[0])
+		}
+		CheckStatus(t, response.Status, "FAILURE")
+		CheckFields(t, response.Fields, "email:Taken")
+		CheckAuditEvents(t, h.Client, req.Email, "signup:failure:email_taken", "signup:success:")
+	}, t)
+}
+
+func TestServer_CreateUserWithoutDatabase(t *testing.T) {
+	testRunnerWithoutDatabase(func(t *testing.T, h *harness) {
+		_, err := h.Client.CreateUser(context.Background(), CreateUserRequest("ada@example.com"))
+		CheckCode(t, err, codes.Unavailable)
+	}, t)
+}
+
+func TestServer_CreateUserPasswordPolicy(t *testing.T) {
+	testRunner(func(t *testing.T, h *harness) {
+		policies := models.PasswordPolicies
+		defer func() { models.PasswordPolicies = policies }()
+		models.PasswordPolicies = models.NewPasswordPolicySet(models.DefaultPasswordPolicy)
+		models.PasswordPolicies.Set("acme", "", models.PasswordPolicy{MinLength: 20, RequireDigit: true})
+
+		req := CreateUserRequest("ada@example.com")
+		req.Password = "ada"
+		response, err := h.Client.CreateUser(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to call CreateUser: %+v", err)
+		}
+		CheckStatus(t, response.Status, "FAILURE")
+		CheckFields(t, response.Fields, "password:MinLength", "password:PersonalInfo")
+		CheckStatus(t, response.Fields[0].Message, "Password must be at least 8 characters long")
+
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+		req.Password = "correct horse battery"
+		response, err = h.Client.CreateUser(ctx, req)
+		if err != nil {
+			t.Fatalf("Failed to call CreateUser: %+v", err)
+		}
+		CheckFields(t, response.Fields, "password:Digit")
+
+		CreateUser(t, h.Client, req)
+	}, t)
+}